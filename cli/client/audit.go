@@ -0,0 +1,216 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// auditLogFilename is the name of the audit log within the local state directory.
+const auditLogFilename = "audit.jsonl"
+
+// auditCollectorTimeout limits how long forwarding an entry to the audit collector may take.
+const auditCollectorTimeout = 5 * time.Second
+
+// AuditEntry is a record of a mutating (non-GET) request made by the CLI.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Cluster  string    `json:"cluster"`
+	Service  string    `json:"service"`
+	User     string    `json:"user,omitempty"`
+	Command  string    `json:"command"`
+	Method   string    `json:"method"`
+	Endpoint string    `json:"endpoint"`
+	// Status is the HTTP status of the response, or zero if no response was received.
+	Status int `json:"status"`
+}
+
+// AuditHook is invoked with each audit entry after it has been written to the local audit log. By
+// default, entries are forwarded to the collector at config.AuditCollectorURL, if configured.
+var AuditHook = forwardAuditEntry
+
+// AuditLogPath returns the path of the local audit log, or an empty string if local state is disabled.
+func AuditLogPath() string {
+	if len(config.StateDir) == 0 {
+		return ""
+	}
+	return filepath.Join(config.StateDir, auditLogFilename)
+}
+
+func isAuditedMethod(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// clusterName returns the host of the cluster URL, e.g. "cluster.example.com".
+func clusterName() string {
+	if parsedURL, err := url.Parse(config.DcosURL); err == nil && len(parsedURL.Host) > 0 {
+		return parsedURL.Host
+	}
+	return config.DcosURL
+}
+
+// tokenUser returns the user ID from the claims of a DC/OS auth token (a JWT), or an empty string if
+// the token couldn't be parsed. The token signature isn't verified: this is only informational.
+func tokenUser(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		UID string `json:"uid"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil {
+		return ""
+	}
+	if len(claims.UID) > 0 {
+		return claims.UID
+	}
+	return claims.Sub
+}
+
+// auditCommandLine returns the CLI's arguments with any auth token values redacted.
+func auditCommandLine(args []string) string {
+	redacted := make([]string, 0, len(args))
+	redactNext := false
+	for _, arg := range args {
+		switch {
+		case redactNext:
+			redacted = append(redacted, "<redacted>")
+			redactNext = false
+		case arg == "--custom-auth-token":
+			redacted = append(redacted, arg)
+			redactNext = true
+		case strings.HasPrefix(arg, "--custom-auth-token="):
+			redacted = append(redacted, "--custom-auth-token=<redacted>")
+		default:
+			redacted = append(redacted, arg)
+		}
+	}
+	return strings.Join(redacted, " ")
+}
+
+func newAuditEntry(request *http.Request, status int) AuditEntry {
+	endpoint := request.URL.Path
+	if len(request.URL.RawQuery) > 0 {
+		endpoint += "?" + request.URL.RawQuery
+	}
+	args := os.Args
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	return AuditEntry{
+		Time:     time.Now().UTC(),
+		Cluster:  clusterName(),
+		Service:  config.ServiceName,
+		User:     tokenUser(config.DcosAuthToken),
+		Command:  auditCommandLine(args),
+		Method:   request.Method,
+		Endpoint: endpoint,
+		Status:   status,
+	}
+}
+
+// recordAudit appends an entry for the request to the local audit log and passes it to the
+// AuditHook, if the request is mutating. Failures are only reported in verbose mode, so that they
+// never prevent the CLI from working.
+func recordAudit(request *http.Request, status int) {
+	if !isAuditedMethod(request.Method) {
+		return
+	}
+	entry := newAuditEntry(request, status)
+	if err := appendAuditEntry(AuditLogPath(), entry); err != nil && config.Verbose {
+		PrintMessage("Failed to write audit log: %s", err)
+	}
+	if AuditHook != nil {
+		AuditHook(entry)
+	}
+}
+
+func appendAuditEntry(path string, entry AuditEntry) error {
+	if len(path) == 0 {
+		return nil
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(entryBytes, '\n'))
+	return err
+}
+
+// ReadAuditLog returns all entries in the local audit log, oldest first.
+func ReadAuditLog() ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+	path := AuditLogPath()
+	if len(path) == 0 {
+		return entries, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d of audit log %s: %s", line, path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// forwardAuditEntry posts the entry as JSON to the configured audit collector, if any. The DC/OS
+// auth token is not sent to the collector.
+func forwardAuditEntry(entry AuditEntry) {
+	if len(config.AuditCollectorURL) == 0 {
+		return
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	// the collector is outside of the cluster, so the cluster's TLS settings don't apply:
+	httpClient := &http.Client{Timeout: auditCollectorTimeout}
+	response, err := httpClient.Post(config.AuditCollectorURL, "application/json", bytes.NewReader(entryBytes))
+	if err != nil {
+		if config.Verbose {
+			PrintMessage("Failed to forward audit entry to %s: %s", config.AuditCollectorURL, err)
+		}
+		return
+	}
+	response.Body.Close()
+	if config.Verbose && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		PrintMessage("Failed to forward audit entry to %s: %s", config.AuditCollectorURL, response.Status)
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	server    *httptest.Server
+	collector *httptest.Server
+	collected []AuditEntry
+}
+
+func (suite *AuditTestSuite) collectorHandler(w http.ResponseWriter, r *http.Request) {
+	var entry AuditEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		suite.T().Fatal(err)
+	}
+	assert.Equal(suite.T(), "", r.Header.Get("Authorization"))
+	suite.collected = append(suite.collected, entry)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (suite *AuditTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	suite.collector = httptest.NewServer(http.HandlerFunc(suite.collectorHandler))
+	suite.collected = nil
+	stateDir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	config.StateDir = stateDir
+	config.DcosURL = suite.server.URL
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"uid":"ops-alice","exp":1500000000}`))
+	config.DcosAuthToken = "eyJhbGciOiJSUzI1NiJ9." + claims + ".c2lnbmF0dXJl"
+	config.ServiceName = "hello-world"
+}
+
+func (suite *AuditTestSuite) TearDownTest() {
+	os.RemoveAll(config.StateDir)
+	config.StateDir = ""
+	config.AuditCollectorURL = ""
+	suite.server.Close()
+	suite.collector.Close()
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) TestRecordsMutatingRequests() {
+	_, err := HTTPServiceGet("v1/plans/deploy")
+	assert.NoError(suite.T(), err)
+	_, err = HTTPServicePostQuery("v1/plans/deploy/forceComplete", "phase=hello&step=hello-0")
+	assert.NoError(suite.T(), err)
+	_, err = HTTPServicePost("v1/pods/hello-0/replace")
+	assert.NoError(suite.T(), err)
+
+	entries, err := ReadAuditLog()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(entries))
+	assert.Equal(suite.T(), "POST", entries[0].Method)
+	assert.Equal(suite.T(), "/service/hello-world/v1/plans/deploy/forceComplete?phase=hello&step=hello-0", entries[0].Endpoint)
+	assert.Equal(suite.T(), http.StatusOK, entries[0].Status)
+	assert.Equal(suite.T(), "ops-alice", entries[0].User)
+	assert.Equal(suite.T(), "hello-world", entries[0].Service)
+	assert.Equal(suite.T(), suite.server.Listener.Addr().String(), entries[0].Cluster)
+	assert.Equal(suite.T(), "/service/hello-world/v1/pods/hello-0/replace", entries[1].Endpoint)
+	assert.False(suite.T(), entries[1].Time.Before(entries[0].Time))
+}
+
+func (suite *AuditTestSuite) TestForwardsToCollector() {
+	config.AuditCollectorURL = suite.collector.URL
+	_, err := HTTPServicePost("v1/pods/hello-0/restart")
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), 1, len(suite.collected))
+	assert.Equal(suite.T(), "/service/hello-world/v1/pods/hello-0/restart", suite.collected[0].Endpoint)
+	assert.Equal(suite.T(), "ops-alice", suite.collected[0].User)
+}
+
+func (suite *AuditTestSuite) TestDisabledWithoutStateDir() {
+	os.RemoveAll(config.StateDir)
+	config.StateDir = ""
+	_, err := HTTPServicePost("v1/pods/hello-0/restart")
+	assert.NoError(suite.T(), err)
+	entries, err := ReadAuditLog()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+func (suite *AuditTestSuite) TestTokenUser() {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"service-account"}`))
+	assert.Equal(suite.T(), "service-account", tokenUser("header."+claims+".signature"))
+	assert.Equal(suite.T(), "", tokenUser("opaque-token"))
+	assert.Equal(suite.T(), "", tokenUser("header.!!!.signature"))
+}
+
+func (suite *AuditTestSuite) TestAuditCommandLine() {
+	assert.Equal(suite.T(), "kafka --custom-auth-token <redacted> pods replace kafka-0",
+		auditCommandLine([]string{"kafka", "--custom-auth-token", "secret", "pods", "replace", "kafka-0"}))
+	assert.Equal(suite.T(), "kafka --custom-auth-token=<redacted> plan stop deploy",
+		auditCommandLine([]string{"kafka", "--custom-auth-token=secret", "plan", "stop", "deploy"}))
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// ConfirmationInput is where responses to confirmation prompts are read from. It may be replaced to
+// allow testing of prompts.
+var ConfirmationInput io.Reader = os.Stdin
+
+// ConfirmationOutput is where confirmation prompts are written to.
+var ConfirmationOutput io.Writer = os.Stdout
+
+func readConfirmation(prompt string) (string, bool) {
+	fmt.Fprint(ConfirmationOutput, prompt)
+	response, err := bufio.NewReader(ConfirmationInput).ReadString('\n')
+	if err != nil && len(response) == 0 {
+		// e.g. stdin is closed: treat as a refusal
+		fmt.Fprintln(ConfirmationOutput)
+		return "", false
+	}
+	return strings.TrimSpace(response), true
+}
+
+// Confirm asks the user to answer yes or no to the prompt, returning whether they answered yes. The
+// prompt is skipped (and true returned) if skip is set, e.g. via a --yes flag, or if the CLI is
+// running non-interactively.
+func Confirm(prompt string, skip bool) bool {
+	if skip || config.NonInteractive {
+		return true
+	}
+	response, ok := readConfirmation(fmt.Sprintf("%s [yes/no]: ", prompt))
+	if !ok {
+		return false
+	}
+	response = strings.ToLower(response)
+	return response == "yes" || response == "y"
+}
+
+// ConfirmWithName describes the impact of an irreversible action, then requires the user to type the
+// name of the affected object (e.g. a pod or topic) to continue, returning whether they did so. The
+// prompt is skipped (and true returned) if skip is set, e.g. via a --yes flag, or if the CLI is
+// running non-interactively.
+func ConfirmWithName(impact, name string, skip bool) bool {
+	if skip || config.NonInteractive {
+		return true
+	}
+	PrintMessage("%s", impact)
+	response, ok := readConfirmation(fmt.Sprintf("This cannot be undone. Type '%s' to continue: ", name))
+	return ok && response == name
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func withConfirmationInput(input string, test func()) string {
+	var output bytes.Buffer
+	ConfirmationInput = strings.NewReader(input)
+	ConfirmationOutput = &output
+	PrintMessage = func(format string, a ...interface{}) (int, error) {
+		return output.WriteString(fmt.Sprintf(format+"\n", a...))
+	}
+	defer func() {
+		ConfirmationInput = os.Stdin
+		ConfirmationOutput = os.Stdout
+		PrintMessage = printMessage
+	}()
+	test()
+	return output.String()
+}
+
+func TestConfirm(t *testing.T) {
+	withConfirmationInput("yes\n", func() { assert.True(t, Confirm("Restart?", false)) })
+	withConfirmationInput("Y\n", func() { assert.True(t, Confirm("Restart?", false)) })
+	withConfirmationInput("no\n", func() { assert.False(t, Confirm("Restart?", false)) })
+	withConfirmationInput("", func() { assert.False(t, Confirm("Restart?", false)) })
+	output := withConfirmationInput("", func() { assert.True(t, Confirm("Restart?", true)) })
+	assert.Equal(t, "", output)
+}
+
+func TestConfirmWithName(t *testing.T) {
+	output := withConfirmationInput("hello-0\n", func() {
+		assert.True(t, ConfirmWithName("Replacing pod hello-0 will delete its volumes.", "hello-0", false))
+	})
+	assert.Equal(t, "Replacing pod hello-0 will delete its volumes.\nThis cannot be undone. Type 'hello-0' to continue: ", output)
+
+	withConfirmationInput("yes\n", func() {
+		assert.False(t, ConfirmWithName("Replacing pod hello-0 will delete its volumes.", "hello-0", false))
+	})
+	withConfirmationInput("hello-1\n", func() {
+		assert.False(t, ConfirmWithName("Replacing pod hello-0 will delete its volumes.", "hello-0", false))
+	})
+}
+
+func TestConfirmNonInteractive(t *testing.T) {
+	config.NonInteractive = true
+	defer func() { config.NonInteractive = false }()
+	output := withConfirmationInput("", func() {
+		assert.True(t, Confirm("Restart?", false))
+		assert.True(t, ConfirmWithName("Replacing pod hello-0 will delete its volumes.", "hello-0", false))
+	})
+	assert.Equal(t, "", output)
+}
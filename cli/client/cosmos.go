@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
 	"path"
@@ -24,6 +25,7 @@ const (
 	badVersionUpdate    = "BadVersionUpdate"
 	jsonSchemaMismatch  = "JsonSchemaMismatch"
 	marathonAppNotFound = "MarathonAppNotFound"
+	packageNotInstalled = "PackageNotInstalled"
 )
 
 // HTTPCosmosPostJSON triggers a HTTP POST request containing jsonPayload to
@@ -33,37 +35,42 @@ func HTTPCosmosPostJSON(urlPath, jsonPayload string) ([]byte, error) {
 	return checkHTTPResponse(httpQuery(createCosmosHTTPJSONRequest("POST", urlPath, jsonPayload)))
 }
 
-type cosmosErrorInstance struct {
-	Pointer string
+// CosmosErrorInstance identifies the options field which failed validation.
+type CosmosErrorInstance struct {
+	Pointer string `json:"pointer"`
 }
 
-type cosmosError struct {
-	Keyword  string
-	Message  string
-	Found    string
-	Expected []string
-	Instance cosmosErrorInstance
+// CosmosValidationError describes an options field which failed validation against the package's
+// config schema.
+type CosmosValidationError struct {
+	Keyword  string              `json:"keyword"`
+	Message  string              `json:"message"`
+	Found    string              `json:"found,omitempty"`
+	Expected []string            `json:"expected,omitempty"`
+	Instance CosmosErrorInstance `json:"instance"`
 	// deliberately omitting:
 	// level
 	// schema
 	// domain
 }
 
-type cosmosData struct {
-	Errors        []cosmosError
-	NewAppID      string
-	OldAppID      string
-	UpdateVersion string
-	ValidVersions []string
+// CosmosData is the data which Cosmos returned along with an error. Which fields are set depends on
+// the type of the error.
+type CosmosData struct {
+	Errors        []CosmosValidationError `json:"errors,omitempty"`
+	NewAppID      string                  `json:"newAppId,omitempty"`
+	OldAppID      string                  `json:"oldAppId,omitempty"`
+	UpdateVersion string                  `json:"updateVersion,omitempty"`
+	ValidVersions []string                `json:"validVersions,omitempty"`
 }
 
 type cosmosErrorResponse struct {
 	ErrorType string `json:"type"`
 	Message   string
-	Data      cosmosData
+	Data      CosmosData
 }
 
-func createBadVersionError(data cosmosData) error {
+func createBadVersionError(data CosmosData) error {
 	var buf bytes.Buffer
 	buf.WriteString(fmt.Sprintf("Unable to update %s to requested version: \"%s\"\n", config.ServiceName, data.UpdateVersion))
 	if len(data.ValidVersions) > 0 {
@@ -72,9 +79,9 @@ func createBadVersionError(data cosmosData) error {
 	} else {
 		buf.WriteString("No valid package versions to update to.")
 	}
-	return fmt.Errorf(buf.String())
+	return &CosmosError{Type: badVersionUpdate, Data: data, message: buf.String()}
 }
-func createJSONMismatchError(data cosmosData) error {
+func createJSONMismatchError(data CosmosData) error {
 	var buf bytes.Buffer
 	writer := bufio.NewWriter(&buf)
 	writer.WriteString("Unable to update %s to requested configuration: options JSON failed validation.")
@@ -87,13 +94,17 @@ func createJSONMismatchError(data cosmosData) error {
 	}
 	tWriter.Flush()
 	writer.Flush()
-	return fmt.Errorf(buf.String(), config.ServiceName)
+	return &CosmosError{Type: jsonSchemaMismatch, Data: data, message: fmt.Sprintf(buf.String(), config.ServiceName)}
 }
 
-func createAppIDChangedError(data cosmosData) error {
+func createAppIDChangedError(data CosmosData) error {
 	errorString := `Could not update service name from "%s" to "%s".
 The service name cannot be changed once installed. Ensure service.name is set to "%s" in options JSON.`
-	return fmt.Errorf(errorString, data.OldAppID, data.NewAppID, data.OldAppID)
+	return &CosmosError{
+		Type:    appIDChanged,
+		Data:    data,
+		message: fmt.Sprintf(errorString, data.OldAppID, data.NewAppID, data.OldAppID),
+	}
 }
 
 func parseCosmosHTTPErrorResponse(response *http.Response, body []byte) error {
@@ -112,12 +123,22 @@ func parseCosmosHTTPErrorResponse(response *http.Response, body []byte) error {
 		case jsonSchemaMismatch:
 			return createJSONMismatchError(errorResponse.Data)
 		case marathonAppNotFound:
-			return createServiceNameError()
+			// Cosmos has confirmed that the service isn't installed:
+			return &ServiceNotFoundError{Service: config.ServiceName, message: createServiceNameError().Error()}
+		case packageNotInstalled:
+			return &ServiceNotFoundError{
+				Service: config.ServiceName,
+				message: fmt.Sprintf("No service named '%s' is installed. Specify a different name with '--name=<name>'.", config.ServiceName),
+			}
 		default:
 			if config.Verbose {
 				PrintJSONBytes(body)
 			}
-			return fmt.Errorf("Could not execute command: %s", errorResponse.Message)
+			return &CosmosError{
+				Type:    errorResponse.ErrorType,
+				Data:    errorResponse.Data,
+				message: fmt.Sprintf("Could not execute command: %s", errorResponse.Message),
+			}
 		}
 	}
 	return createResponseError(response)
@@ -137,15 +158,113 @@ func checkCosmosHTTPResponse(response *http.Response, body []byte) error {
 }
 
 func createCosmosHTTPJSONRequest(method, urlPath, jsonPayload string) *http.Request {
-	// NOTE: this explicitly only allows use of /service/ endpoints within Cosmos. See DCOS-15772
-	// for the "correct" solution to allow cleaner use of /package/ endpoints.
+	// NOTE: this is only for use with /service/ endpoints within Cosmos, which only offer a single
+	// version of each media type. Use createCosmosPackageHTTPJSONRequest for /package/ endpoints.
 	endpoint := strings.Replace(urlPath, "/", ".", -1)
 	acceptHeader := fmt.Sprintf("application/vnd.dcos.service.%s-response+json;charset=utf-8;version=v1", endpoint)
 	contentTypeHeader := fmt.Sprintf("application/vnd.dcos.service.%s-request+json;charset=utf-8;version=v1", endpoint)
 	return createHTTPRawRequest(method, createCosmosURL(urlPath), jsonPayload, acceptHeader, contentTypeHeader)
 }
 
+// cosmosPackageResponseVersions lists the versions of each Cosmos /package/ endpoint's response
+// media type which the CLI can parse, most preferred first.
+var cosmosPackageResponseVersions = map[string][]string{
+	"install":   {"v2", "v1"},
+	"list":      {"v1"},
+	"uninstall": {"v1"},
+}
+
+// cosmosPackageMediaType returns the media type of a /package/ endpoint's request or response,
+// e.g. "application/vnd.dcos.package.install-request+json;charset=utf-8;version=v1".
+func cosmosPackageMediaType(endpoint, kind, version string) string {
+	return fmt.Sprintf("application/vnd.dcos.package.%s-%s+json;charset=utf-8;version=%s", endpoint, kind, version)
+}
+
+// cosmosPackageAcceptHeader lists each supported response version in order of preference, so that
+// Cosmos responds with the newest version which both sides understand.
+func cosmosPackageAcceptHeader(endpoint string) string {
+	versions := cosmosPackageResponseVersions[endpoint]
+	mediaTypes := make([]string, 0, len(versions))
+	for i, version := range versions {
+		mediaType := cosmosPackageMediaType(endpoint, "response", version)
+		if i > 0 {
+			mediaType += fmt.Sprintf(";q=0.%d", 10-i)
+		}
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	return strings.Join(mediaTypes, ",")
+}
+
+// cosmosResponseVersion returns the version of the media type which Cosmos responded with, or an
+// empty string if it couldn't be determined.
+func cosmosResponseVersion(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["version"]
+}
+
+// HTTPCosmosPackagePostJSON triggers a HTTP POST request containing jsonPayload to
+// https://dcos.cluster/cosmos/package/<urlPath>, negotiating the version of the response media
+// type. Returns the response along with its version, e.g. "v2".
+func HTTPCosmosPackagePostJSON(urlPath, jsonPayload string) ([]byte, string, error) {
+	if _, ok := cosmosPackageResponseVersions[urlPath]; !ok {
+		return nil, "", fmt.Errorf("Unsupported Cosmos package endpoint: %s", urlPath)
+	}
+	SetCustomResponseCheck(checkCosmosPackageHTTPResponse)
+	response := httpQuery(createCosmosPackageHTTPJSONRequest("POST", urlPath, jsonPayload))
+	body, err := checkHTTPResponse(response)
+	if err != nil {
+		return body, "", err
+	}
+	version := cosmosResponseVersion(response.Header.Get("Content-Type"))
+	if len(version) == 0 {
+		// assume the preferred version, as older Cosmos versions may not label their responses:
+		return body, cosmosPackageResponseVersions[urlPath][0], nil
+	}
+	for _, supported := range cosmosPackageResponseVersions[urlPath] {
+		if version == supported {
+			return body, version, nil
+		}
+	}
+	return body, version, fmt.Errorf("Cosmos responded to package %s with unsupported response version %s (supported: %s)",
+		urlPath, version, strings.Join(cosmosPackageResponseVersions[urlPath], ", "))
+}
+
+func createCosmosPackageHTTPJSONRequest(method, urlPath, jsonPayload string) *http.Request {
+	acceptHeader := cosmosPackageAcceptHeader(urlPath)
+	contentTypeHeader := cosmosPackageMediaType(urlPath, "request", "v1")
+	return createHTTPRawRequest(method, createCosmosPackageURL(urlPath), jsonPayload, acceptHeader, contentTypeHeader)
+}
+
+func checkCosmosPackageHTTPResponse(response *http.Response, body []byte) error {
+	switch {
+	case response.StatusCode == http.StatusNotAcceptable:
+		return fmt.Errorf("Cosmos doesn't support any of the response versions of %s understood by this CLI. Is the DC/OS CLI module up to date?",
+			response.Request.URL.Path)
+	case response.StatusCode >= 400:
+		var errorResponse cosmosErrorResponse
+		if err := json.Unmarshal(body, &errorResponse); err != nil || len(errorResponse.ErrorType) == 0 {
+			// not a Cosmos error (e.g. from Adminrouter): avoid diagnosing the service's scheduler
+			return createResponseError(response)
+		}
+		return parseCosmosHTTPErrorResponse(response, body)
+	}
+	return nil
+}
+
+// createCosmosURL returns the URL of a /service/ endpoint within Cosmos.
 func createCosmosURL(urlPath string) *url.URL {
+	return createCosmosAPIURL("service", urlPath)
+}
+
+// createCosmosPackageURL returns the URL of a /package/ endpoint within Cosmos.
+func createCosmosPackageURL(urlPath string) *url.URL {
+	return createCosmosAPIURL("package", urlPath)
+}
+
+func createCosmosAPIURL(api, urlPath string) *url.URL {
 	// Try to fetch the Cosmos URL from the system configuration
 	if len(config.CosmosURL) == 0 {
 		config.CosmosURL = OptionalCLIConfigValue(cosmosURLConfigKey)
@@ -153,10 +272,10 @@ func createCosmosURL(urlPath string) *url.URL {
 
 	// Use Cosmos URL if we have it specified
 	if len(config.CosmosURL) > 0 {
-		joinedURLPath := path.Join("service", urlPath) // e.g. https://<cosmos_url>/service/describe
+		joinedURLPath := path.Join(api, urlPath) // e.g. https://<cosmos_url>/service/describe
 		return createURL(config.CosmosURL, joinedURLPath, "")
 	}
 	getDCOSURL()
-	joinedURLPath := path.Join("cosmos", "service", urlPath) // e.g. https://<dcos_url>/cosmos/service/describe
+	joinedURLPath := path.Join("cosmos", api, urlPath) // e.g. https://<dcos_url>/cosmos/service/describe
 	return createURL(config.DcosURL, joinedURLPath, "")
 }
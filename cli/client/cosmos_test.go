@@ -25,6 +25,10 @@ func (suite *CosmosTestSuite) printRecorder(format string, a ...interface{}) (n
 	return 0, nil // this is probably sub-optimal in the general sense
 }
 
+func (suite *CosmosTestSuite) errorRecorder(err error) {
+	suite.printRecorder("%s", err.Error())
+}
+
 func (suite *CosmosTestSuite) loadFile(filename string) []byte {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -40,6 +44,7 @@ func (suite *CosmosTestSuite) SetupSuite() {
 	// reassign printing functions to allow us to check output
 	PrintMessage = suite.printRecorder
 	PrintMessageAndExit = suite.printRecorder
+	PrintErrorAndExit = suite.errorRecorder
 }
 
 func (suite *CosmosTestSuite) SetupTest() {
@@ -102,6 +107,18 @@ func (suite *CosmosTestSuite) TestAppNotFoundErrorResponse() {
 	suite.test400ErrorResponse("testdata/responses/cosmos/1.10/enterprise/bad-name.json", "testdata/output/bad-name.txt")
 }
 
+func (suite *CosmosTestSuite) TestCosmosErrorData() {
+	fourHundredResponse, body := suite.createExampleResponse(http.StatusBadRequest, "testdata/responses/cosmos/1.10/enterprise/bad-version.json")
+
+	err := checkCosmosHTTPResponse(&fourHundredResponse, body)
+	if assert.IsType(suite.T(), &CosmosError{}, err) {
+		cosmosErr := err.(*CosmosError)
+		assert.Equal(suite.T(), badVersionUpdate, cosmosErr.Type)
+		assert.NotEmpty(suite.T(), cosmosErr.Data.ValidVersions)
+	}
+	assert.Equal(suite.T(), ExitCodeCosmos, ExitCode(err))
+}
+
 func (suite *CosmosTestSuite) TestBadVersionErrorResponse() {
 	// create 400 responses for BadVersionUpdate
 	suite.test400ErrorResponse("testdata/responses/cosmos/1.10/enterprise/bad-version.json", "testdata/output/bad-version.txt")
@@ -160,3 +177,23 @@ func (suite *CosmosTestSuite) TestCosmosUrl() {
 	assert.Equal(suite.T(), "https://my.dcos.url/cosmos/service/describe", describeURL.String())
 	assert.Equal(suite.T(), "https://my.dcos.url/cosmos/service/update", updateURL.String())
 }
+
+func (suite *CosmosTestSuite) TestCosmosPackageUrl() {
+	installURL := createCosmosPackageURL("install")
+	assert.Equal(suite.T(), "https://my.dcos.url/cosmos/package/install", installURL.String())
+}
+
+func (suite *CosmosTestSuite) TestCosmosPackageAcceptHeader() {
+	assert.Equal(suite.T(),
+		"application/vnd.dcos.package.install-response+json;charset=utf-8;version=v2,"+
+			"application/vnd.dcos.package.install-response+json;charset=utf-8;version=v1;q=0.9",
+		cosmosPackageAcceptHeader("install"))
+	assert.Equal(suite.T(), "application/vnd.dcos.package.list-response+json;charset=utf-8;version=v1",
+		cosmosPackageAcceptHeader("list"))
+}
+
+func (suite *CosmosTestSuite) TestCosmosResponseVersion() {
+	assert.Equal(suite.T(), "v2", cosmosResponseVersion("application/vnd.dcos.package.install-response+json;charset=utf-8;version=v2"))
+	assert.Equal(suite.T(), "", cosmosResponseVersion("application/json"))
+	assert.Equal(suite.T(), "", cosmosResponseVersion(""))
+}
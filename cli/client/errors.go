@@ -0,0 +1,178 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// Exit codes returned by the CLI when a command fails, allowing scripts to tell failures apart:
+//
+//	0  success
+//	1  any other failure, including invalid arguments and cancelled prompts
+//	3  authentication failed: the auth token is missing, invalid or expired (AuthError)
+//	4  no service with the requested name is installed (ServiceNotFoundError)
+//	5  the service's scheduler couldn't service the request, e.g. it is still deploying (SchedulerUnavailableError)
+//	6  the requested plan, phase or step doesn't exist (PlanElementNotFoundError)
+//	7  Cosmos rejected the request, e.g. invalid options or package version (CosmosError)
+//	8  any other unsuccessful HTTP response (HTTPError)
+const (
+	ExitCodeSuccess              = 0
+	ExitCodeError                = 1
+	ExitCodeAuth                 = 3
+	ExitCodeServiceNotFound      = 4
+	ExitCodeSchedulerUnavailable = 5
+	ExitCodePlanElementNotFound  = 6
+	ExitCodeCosmos               = 7
+	ExitCodeHTTP                 = 8
+)
+
+// Supported values for config.ErrorFormat.
+const (
+	ErrorFormatText = "text"
+	ErrorFormatJSON = "json"
+)
+
+// ErrorOutput is where errors are written to by PrintErrorAndExit and PrintMessageAndExit.
+var ErrorOutput io.Writer = os.Stderr
+
+// AuthError is returned when the cluster rejects the request's credentials.
+type AuthError struct {
+	URL string `json:"url"`
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf(`Got 401 Unauthorized response from %s
+"- Bad auth token? Run 'dcos auth login' to log in.`, e.URL)
+}
+
+// ServiceNotFoundError is returned when no service with the requested name is installed. Similar
+// lists the IDs of installed services with similar names, if any.
+type ServiceNotFoundError struct {
+	Service string   `json:"service"`
+	Similar []string `json:"similar,omitempty"`
+	message string
+}
+
+func (e *ServiceNotFoundError) Error() string {
+	return e.message
+}
+
+// NewServiceNotFoundError returns a ServiceNotFoundError for the named service with the provided message.
+func NewServiceNotFoundError(service string, message string) *ServiceNotFoundError {
+	return &ServiceNotFoundError{Service: service, message: message}
+}
+
+// SchedulerUnavailableError is returned when the service's scheduler couldn't be reached or failed
+// to service the request, e.g. because it is still being deployed or is crash-looping.
+type SchedulerUnavailableError struct {
+	Service string `json:"service"`
+	message string
+}
+
+func (e *SchedulerUnavailableError) Error() string {
+	return e.message
+}
+
+// PlanElementNotFoundError is returned when the scheduler has no plan, phase or step matching the request.
+type PlanElementNotFoundError struct {
+	Plan  string `json:"plan,omitempty"`
+	Phase string `json:"phase,omitempty"`
+	Step  string `json:"step,omitempty"`
+}
+
+func (e *PlanElementNotFoundError) Error() string {
+	return "Plan, phase and/or step does not exist."
+}
+
+// CosmosError is returned when Cosmos rejects a request, with the error type and data it returned.
+type CosmosError struct {
+	Type    string     `json:"cosmosType"`
+	Data    CosmosData `json:"data"`
+	message string
+}
+
+func (e *CosmosError) Error() string {
+	return e.message
+}
+
+// HTTPError is returned for unsuccessful HTTP responses which don't have a more specific error.
+type HTTPError struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Status     string `json:"status"`
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %s Query for %s failed: %s", e.Method, e.URL, e.Status)
+}
+
+// ExitCode returns the exit code which the CLI should return for the error.
+func ExitCode(err error) int {
+	switch err.(type) {
+	case nil:
+		return ExitCodeSuccess
+	case *AuthError:
+		return ExitCodeAuth
+	case *ServiceNotFoundError:
+		return ExitCodeServiceNotFound
+	case *SchedulerUnavailableError:
+		return ExitCodeSchedulerUnavailable
+	case *PlanElementNotFoundError:
+		return ExitCodePlanElementNotFound
+	case *CosmosError:
+		return ExitCodeCosmos
+	case *HTTPError:
+		return ExitCodeHTTP
+	default:
+		return ExitCodeError
+	}
+}
+
+// errorType returns the name of the error's type as shown in JSON errors, e.g. "AuthError".
+func errorType(err error) string {
+	switch err.(type) {
+	case *AuthError:
+		return "AuthError"
+	case *ServiceNotFoundError:
+		return "ServiceNotFoundError"
+	case *SchedulerUnavailableError:
+		return "SchedulerUnavailableError"
+	case *PlanElementNotFoundError:
+		return "PlanElementNotFoundError"
+	case *CosmosError:
+		return "CosmosError"
+	case *HTTPError:
+		return "HTTPError"
+	default:
+		return "Error"
+	}
+}
+
+type errorJSON struct {
+	Type     string      `json:"type"`
+	Message  string      `json:"message"`
+	ExitCode int         `json:"exitCode"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// FormatError returns the error as it should be shown to the user, according to config.ErrorFormat.
+// JSON errors have the type, message and exit code of the error, along with any typed details.
+func FormatError(err error) string {
+	if config.ErrorFormat != ErrorFormatJSON {
+		return err.Error()
+	}
+	output := errorJSON{Type: errorType(err), Message: err.Error(), ExitCode: ExitCode(err)}
+	if output.Type != "Error" {
+		output.Details = err
+	}
+	outputBytes, marshalErr := json.Marshal(output)
+	if marshalErr != nil {
+		return err.Error()
+	}
+	return string(outputBytes)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitCodeSuccess, ExitCode(nil))
+	assert.Equal(t, ExitCodeError, ExitCode(errors.New("failed")))
+	assert.Equal(t, ExitCodeAuth, ExitCode(&AuthError{URL: "https://my.dcos.url/service/hello-world/v1/plans"}))
+	assert.Equal(t, ExitCodeServiceNotFound, ExitCode(&ServiceNotFoundError{Service: "hello-world"}))
+	assert.Equal(t, ExitCodeSchedulerUnavailable, ExitCode(&SchedulerUnavailableError{Service: "hello-world"}))
+	assert.Equal(t, ExitCodePlanElementNotFound, ExitCode(&PlanElementNotFoundError{Plan: "deploy"}))
+	assert.Equal(t, ExitCodeCosmos, ExitCode(&CosmosError{Type: badVersionUpdate}))
+	assert.Equal(t, ExitCodeHTTP, ExitCode(&HTTPError{StatusCode: 503}))
+}
+
+func TestFormatErrorText(t *testing.T) {
+	config.ErrorFormat = ErrorFormatText
+	assert.Equal(t, "Plan, phase and/or step does not exist.", FormatError(&PlanElementNotFoundError{Plan: "deploy"}))
+	assert.Equal(t, "failed", FormatError(errors.New("failed")))
+}
+
+func TestFormatErrorJSON(t *testing.T) {
+	config.ErrorFormat = ErrorFormatJSON
+	defer func() { config.ErrorFormat = ErrorFormatText }()
+
+	assert.Equal(t,
+		`{"type":"PlanElementNotFoundError","message":"Plan, phase and/or step does not exist.","exitCode":6,"details":{"plan":"deploy","phase":"hello"}}`,
+		FormatError(&PlanElementNotFoundError{Plan: "deploy", Phase: "hello"}))
+	assert.Equal(t,
+		`{"type":"HTTPError","message":"HTTP GET Query for https://my.dcos.url/v1/state failed: 503 Service Unavailable","exitCode":8,"details":{"method":"GET","url":"https://my.dcos.url/v1/state","statusCode":503,"status":"503 Service Unavailable"}}`,
+		FormatError(&HTTPError{Method: "GET", URL: "https://my.dcos.url/v1/state", StatusCode: 503, Status: "503 Service Unavailable"}))
+	assert.Equal(t, `{"type":"Error","message":"failed","exitCode":1}`, FormatError(errors.New("failed")))
+}
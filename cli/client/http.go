@@ -116,6 +116,39 @@ func HTTPServicePutJSON(urlPath, jsonPayload string) ([]byte, error) {
 }
 
 func httpQuery(request *http.Request) *http.Response {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: getTLSConfig()}}
+	var err interface{}
+	response, err := client.Do(request)
+	if response != nil {
+		recordAudit(request, response.StatusCode)
+	} else {
+		recordAudit(request, 0)
+	}
+	switch err.(type) {
+	case *url.Error:
+		// extract wrapped error
+		err = err.(*url.Error).Err
+	}
+	if err != nil {
+		switch err.(type) {
+		case x509.UnknownAuthorityError:
+			// custom suggestions for a certificate error:
+			PrintMessageAndExit("HTTP %s Query for %s failed: %s\n%s\n%s", request.Method, request.URL, err,
+				"- Is the cluster CA certificate configured correctly? Check 'dcos config show core.ssl_verify'.",
+				"- To ignore the unvalidated certificate and force your command (INSECURE), use --force-insecure")
+		default:
+			PrintMessageAndExit("HTTP %s Query for %s failed: %s\n%s\n%s", request.Method, request.URL, err,
+				"- Is 'core.dcos_url' set correctly? Check 'dcos config show core.dcos_url'.",
+				"- Is 'core.dcos_acs_token' set correctly? Run 'dcos auth login' to log in.")
+		}
+	}
+	if config.Verbose {
+		PrintMessage("Response: %s (%d bytes)", response.Status, response.ContentLength)
+	}
+	return response
+}
+
+func getTLSConfig() *tls.Config {
 	if config.TLSForceInsecure { // user override via '--force-insecure'
 		config.TLSCliSetting = config.TLSUnverified
 	}
@@ -155,32 +188,7 @@ func httpQuery(request *http.Request) *http.Response {
 		certPool.AppendCertsFromPEM(cert)
 		tlsConfig.RootCAs = certPool
 	}
-
-	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
-	var err interface{}
-	response, err := client.Do(request)
-	switch err.(type) {
-	case *url.Error:
-		// extract wrapped error
-		err = err.(*url.Error).Err
-	}
-	if err != nil {
-		switch err.(type) {
-		case x509.UnknownAuthorityError:
-			// custom suggestions for a certificate error:
-			PrintMessage("HTTP %s Query for %s failed: %s", request.Method, request.URL, err)
-			PrintMessage("- Is the cluster CA certificate configured correctly? Check 'dcos config show core.ssl_verify'.")
-			PrintMessageAndExit("- To ignore the unvalidated certificate and force your command (INSECURE), use --force-insecure")
-		default:
-			PrintMessage("HTTP %s Query for %s failed: %s", request.Method, request.URL, err)
-			PrintMessage("- Is 'core.dcos_url' set correctly? Check 'dcos config show core.dcos_url'.")
-			PrintMessageAndExit("- Is 'core.dcos_acs_token' set correctly? Run 'dcos auth login' to log in.")
-		}
-	}
-	if config.Verbose {
-		PrintMessage("Response: %s (%d bytes)", response.Status, response.ContentLength)
-	}
-	return response
+	return tlsConfig
 }
 
 func createServiceHTTPJSONRequest(method, urlPath, jsonPayload string) *http.Request {
@@ -242,14 +250,7 @@ func createHTTPURLRequest(method string, url *url.URL, payload, accept, contentT
 	if err != nil {
 		PrintMessageAndExit("Failed to create HTTP %s request for %s: %s", method, url, err)
 	}
-	if len(config.DcosAuthToken) == 0 {
-		// if the token wasnt manually provided by the user, try to fetch it from the main CLI.
-		// this value is optional: clusters can be configured to not require any auth
-		config.DcosAuthToken = OptionalCLIConfigValue("core.dcos_acs_token")
-	}
-	if len(config.DcosAuthToken) != 0 {
-		request.Header.Set("Authorization", fmt.Sprintf("token=%s", config.DcosAuthToken))
-	}
+	setAuthHeader(request, getAuthToken())
 	if len(accept) != 0 {
 		request.Header.Set("Accept", accept)
 	}
@@ -258,3 +259,18 @@ func createHTTPURLRequest(method string, url *url.URL, payload, accept, contentT
 	}
 	return request
 }
+
+func getAuthToken() string {
+	if len(config.DcosAuthToken) == 0 {
+		// if the token wasnt manually provided by the user, try to fetch it from the main CLI.
+		// this value is optional: clusters can be configured to not require any auth
+		config.DcosAuthToken = OptionalCLIConfigValue("core.dcos_acs_token")
+	}
+	return config.DcosAuthToken
+}
+
+func setAuthHeader(request *http.Request, token string) {
+	if len(token) != 0 {
+		request.Header.Set("Authorization", fmt.Sprintf("token=%s", token))
+	}
+}
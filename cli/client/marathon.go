@@ -0,0 +1,371 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// MarathonApp is the subset of a Marathon app definition, along with its embedded
+// deployments/tasks/failure information, which is used by the CLI.
+type MarathonApp struct {
+	ID              string               `json:"id"`
+	Version         string               `json:"version"`
+	Instances       int                  `json:"instances"`
+	TasksRunning    int                  `json:"tasksRunning"`
+	TasksStaged     int                  `json:"tasksStaged"`
+	TasksHealthy    int                  `json:"tasksHealthy"`
+	TasksUnhealthy  int                  `json:"tasksUnhealthy"`
+	Labels          map[string]string    `json:"labels"`
+	Deployments     []MarathonDeployment `json:"deployments"`
+	Tasks           []MarathonTask       `json:"tasks"`
+	LastTaskFailure *MarathonTaskFailure `json:"lastTaskFailure"`
+}
+
+// MarathonDeployment describes an in-progress Marathon deployment. Only the ID is provided when the
+// deployment is embedded in an app.
+type MarathonDeployment struct {
+	ID             string                     `json:"id"`
+	CurrentStep    int                        `json:"currentStep"`
+	TotalSteps     int                        `json:"totalSteps"`
+	CurrentActions []MarathonDeploymentAction `json:"currentActions"`
+}
+
+// MarathonDeploymentAction is an action which is currently being performed by a Marathon deployment.
+type MarathonDeploymentAction struct {
+	Action string `json:"action"`
+	App    string `json:"app"`
+}
+
+// MarathonTask describes a running instance of a Marathon app.
+type MarathonTask struct {
+	ID                 string                      `json:"id"`
+	Host               string                      `json:"host"`
+	SlaveID            string                      `json:"slaveId"`
+	State              string                      `json:"state"`
+	StartedAt          string                      `json:"startedAt"`
+	Version            string                      `json:"version"`
+	HealthCheckResults []MarathonHealthCheckResult `json:"healthCheckResults"`
+}
+
+// MarathonHealthCheckResult is the most recent result of a health check against a Marathon task.
+type MarathonHealthCheckResult struct {
+	Alive bool `json:"alive"`
+}
+
+// MarathonTaskFailure describes the most recent failed task of a Marathon app.
+type MarathonTaskFailure struct {
+	Message   string `json:"message"`
+	State     string `json:"state"`
+	Host      string `json:"host"`
+	Timestamp string `json:"timestamp"`
+}
+
+type marathonAppResponse struct {
+	App MarathonApp `json:"app"`
+}
+
+type marathonAppsResponse struct {
+	Apps []MarathonApp `json:"apps"`
+}
+
+// HTTPMarathonGet triggers a HTTP GET request to:
+// <config.DcosURL>/marathon/<urlPath>?<urlQuery>
+// Unlike the service queries, Marathon responses are never passed through any custom or default
+// response checks: any non-2xx response is returned as a generic error alongside its status code.
+func HTTPMarathonGet(urlPath, urlQuery string) ([]byte, int, error) {
+	return checkMarathonHTTPResponse(httpQuery(createMarathonHTTPRequest("GET", urlPath, urlQuery)))
+}
+
+// HTTPMarathonPost triggers a HTTP POST request to:
+// <config.DcosURL>/marathon/<urlPath>?<urlQuery>
+func HTTPMarathonPost(urlPath, urlQuery string) ([]byte, int, error) {
+	return checkMarathonHTTPResponse(httpQuery(createMarathonHTTPRequest("POST", urlPath, urlQuery)))
+}
+
+func checkMarathonHTTPResponse(response *http.Response) ([]byte, int, error) {
+	body, err := getResponseBytes(response)
+	if err != nil {
+		return nil, response.StatusCode, fmt.Errorf("Failed to read response data from %s %s query: %s",
+			response.Request.Method, response.Request.URL, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return body, response.StatusCode, createResponseError(response)
+	}
+	return body, response.StatusCode, nil
+}
+
+func createMarathonHTTPRequest(method, urlPath, urlQuery string) *http.Request {
+	getDCOSURL()
+	joinedURLPath := path.Join("marathon", urlPath) // e.g. https://<dcos_url>/marathon/v2/apps/kafka
+	return createHTTPRawRequest(method, createURL(config.DcosURL, joinedURLPath, urlQuery), "", "", "")
+}
+
+// MarathonAppID returns the Marathon app ID for the provided service name, e.g. "/folder/kafka".
+func MarathonAppID(serviceName string) string {
+	return "/" + strings.Trim(serviceName, "/")
+}
+
+// GetMarathonApp returns the Marathon app with the provided ID, including any in-progress
+// deployments, its tasks, and its last task failure. If the app doesn't exist, this returns nil.
+func GetMarathonApp(appID string) (*MarathonApp, error) {
+	query := url.Values{}
+	query.Add("embed", "app.deployments")
+	query.Add("embed", "app.tasks")
+	query.Add("embed", "app.lastTaskFailure")
+	responseBytes, statusCode, err := HTTPMarathonGet(path.Join("v2", "apps", appID), query.Encode())
+	if statusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var response marathonAppResponse
+	err = json.Unmarshal(responseBytes, &response)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Marathon app response: %s", err)
+	}
+	return &response.App, nil
+}
+
+// GetMarathonDeployment returns the in-progress Marathon deployment with the provided ID. If the
+// deployment has finished (or never existed), this returns nil.
+func GetMarathonDeployment(deploymentID string) (*MarathonDeployment, error) {
+	responseBytes, _, err := HTTPMarathonGet("v2/deployments", "")
+	if err != nil {
+		return nil, err
+	}
+	var deployments []MarathonDeployment
+	err = json.Unmarshal(responseBytes, &deployments)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Marathon deployments response: %s", err)
+	}
+	for _, deployment := range deployments {
+		if deployment.ID == deploymentID {
+			return &deployment, nil
+		}
+	}
+	return nil, nil
+}
+
+type marathonAppVersionsResponse struct {
+	Versions []string `json:"versions"`
+}
+
+// GetMarathonAppVersions returns the timestamps of all stored versions of the Marathon app with the
+// provided ID, most recent first.
+func GetMarathonAppVersions(appID string) ([]string, error) {
+	responseBytes, _, err := HTTPMarathonGet(path.Join("v2", "apps", appID, "versions"), "")
+	if err != nil {
+		return nil, err
+	}
+	var response marathonAppVersionsResponse
+	err = json.Unmarshal(responseBytes, &response)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Marathon app versions response: %s", err)
+	}
+	return response.Versions, nil
+}
+
+// GetMarathonAppVersion returns the definition of the Marathon app with the provided ID as of the
+// provided version timestamp.
+func GetMarathonAppVersion(appID, version string) (*MarathonApp, error) {
+	responseBytes, _, err := HTTPMarathonGet(path.Join("v2", "apps", appID, "versions", version), "")
+	if err != nil {
+		return nil, err
+	}
+	var app MarathonApp
+	err = json.Unmarshal(responseBytes, &app)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Marathon app version response: %s", err)
+	}
+	return &app, nil
+}
+
+type marathonDeploymentResponse struct {
+	Version      string `json:"version"`
+	DeploymentID string `json:"deploymentId"`
+}
+
+// RestartMarathonApp triggers a rolling restart of all tasks in the Marathon app with the provided ID,
+// returning the ID of the resulting Marathon deployment. If force is set, any existing deployment
+// of the app is cancelled.
+func RestartMarathonApp(appID string, force bool) (string, error) {
+	query := url.Values{}
+	if force {
+		query.Set("force", "true")
+	}
+	responseBytes, statusCode, err := HTTPMarathonPost(path.Join("v2", "apps", appID, "restart"), query.Encode())
+	if statusCode == http.StatusConflict {
+		return "", fmt.Errorf("Marathon app %s is locked by an in-progress deployment.", appID)
+	}
+	if err != nil {
+		return "", err
+	}
+	var response marathonDeploymentResponse
+	err = json.Unmarshal(responseBytes, &response)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse Marathon restart response: %s", err)
+	}
+	return response.DeploymentID, nil
+}
+
+func getMarathonAppIDs() ([]string, error) {
+	responseBytes, _, err := HTTPMarathonGet("v2/apps", "")
+	if err != nil {
+		return nil, err
+	}
+	var response marathonAppsResponse
+	err = json.Unmarshal(responseBytes, &response)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Marathon apps response: %s", err)
+	}
+	appIDs := make([]string, 0, len(response.Apps))
+	for _, app := range response.Apps {
+		appIDs = append(appIDs, app.ID)
+	}
+	return appIDs, nil
+}
+
+// maxSimilarAppIDs limits the number of suggestions printed for a missing service.
+const maxSimilarAppIDs = 5
+
+// similarAppIDs returns the app IDs which look like they may have been intended instead of appID:
+// the same name in a different group, a name containing (or contained by) the requested name, or
+// a name within a couple of typos of the requested name.
+func similarAppIDs(appID string, appIDs []string) []string {
+	wantName := path.Base(appID)
+	similar := make([]string, 0)
+	for _, candidateID := range appIDs {
+		if candidateID == appID {
+			continue
+		}
+		candidateName := path.Base(candidateID)
+		if candidateName == wantName ||
+			strings.Contains(candidateName, wantName) ||
+			strings.Contains(wantName, candidateName) ||
+			editDistance(candidateName, wantName) <= 2 {
+			similar = append(similar, candidateID)
+		}
+	}
+	sort.Strings(similar)
+	if len(similar) > maxSimilarAppIDs {
+		similar = similar[:maxSimilarAppIDs]
+	}
+	return similar
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// diagnoseServiceError queries Marathon for the state of the service's scheduler, and returns an
+// error describing why the scheduler couldn't service the request. If Marathon can't be queried,
+// the generic createServiceNameError() is returned.
+func diagnoseServiceError(response *http.Response) error {
+	appID := MarathonAppID(config.ServiceName)
+	app, err := GetMarathonApp(appID)
+	if err != nil {
+		if config.Verbose {
+			PrintMessage("Unable to retrieve scheduler state from Marathon: %s", err)
+		}
+		return createServiceNameError()
+	}
+	if app == nil {
+		var similar []string
+		appIDs, err := getMarathonAppIDs()
+		if err == nil {
+			similar = similarAppIDs(appID, appIDs)
+		} else if config.Verbose {
+			PrintMessage("Unable to retrieve list of Marathon apps: %s", err)
+		}
+		return createServiceNotFoundError(similar)
+	}
+	return createSchedulerStateError(app, response)
+}
+
+func createServiceNotFoundError(similar []string) error {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("No service named '%s' is installed.\n", config.ServiceName))
+	if len(similar) > 0 {
+		buf.WriteString("Did you mean one of these? Specify a different name with '--name=<name>':\n")
+		for _, appID := range similar {
+			buf.WriteString(fmt.Sprintf("- %s\n", strings.TrimLeft(appID, "/")))
+		}
+	} else {
+		buf.WriteString("Did you provide the correct service name? Specify a different name with '--name=<name>'.\n")
+	}
+	return &ServiceNotFoundError{
+		Service: config.ServiceName,
+		Similar: similar,
+		message: strings.TrimRight(buf.String(), "\n"),
+	}
+}
+
+func createSchedulerStateError(app *MarathonApp, response *http.Response) error {
+	var buf bytes.Buffer
+	switch {
+	case len(app.Deployments) > 0:
+		deploymentIDs := make([]string, 0, len(app.Deployments))
+		for _, deployment := range app.Deployments {
+			deploymentIDs = append(deploymentIDs, deployment.ID)
+		}
+		buf.WriteString(fmt.Sprintf("The scheduler for service '%s' is currently being deployed by Marathon (deployment %s).\n",
+			config.ServiceName, strings.Join(deploymentIDs, ", ")))
+		buf.WriteString("Wait for the deployment to complete and try again.")
+	case app.TasksRunning == 0:
+		buf.WriteString(fmt.Sprintf("The scheduler for service '%s' is not running (%d of %d instances running, %d staged).\n",
+			config.ServiceName, app.TasksRunning, app.Instances, app.TasksStaged))
+		if app.LastTaskFailure != nil {
+			buf.WriteString(fmt.Sprintf("The scheduler may be crash-looping. Last failure (%s at %s on %s): %s\n",
+				app.LastTaskFailure.State, app.LastTaskFailure.Timestamp, app.LastTaskFailure.Host, app.LastTaskFailure.Message))
+		}
+		buf.WriteString("Check the scheduler's logs with 'dcos task log'.")
+	case app.TasksUnhealthy > 0:
+		buf.WriteString(fmt.Sprintf("The scheduler for service '%s' is running but failing its health checks (%d healthy, %d unhealthy).\n",
+			config.ServiceName, app.TasksHealthy, app.TasksUnhealthy))
+		if app.LastTaskFailure != nil {
+			buf.WriteString(fmt.Sprintf("Last failure (%s at %s on %s): %s\n",
+				app.LastTaskFailure.State, app.LastTaskFailure.Timestamp, app.LastTaskFailure.Host, app.LastTaskFailure.Message))
+		}
+		buf.WriteString("Check the scheduler's logs with 'dcos task log'.")
+	default:
+		buf.WriteString(fmt.Sprintf("The scheduler for service '%s' is running according to Marathon, but the request failed.\n",
+			config.ServiceName))
+		buf.WriteString(fmt.Sprintf("HTTP %s Query for %s failed: %s\n",
+			response.Request.Method, response.Request.URL, response.Status))
+		buf.WriteString("Was the service recently installed or updated? It may still be initializing, wait a bit and try again.")
+	}
+	return &SchedulerUnavailableError{Service: config.ServiceName, message: buf.String()}
+}
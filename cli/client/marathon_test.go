@@ -0,0 +1,121 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MarathonTestSuite struct {
+	suite.Suite
+	server       *httptest.Server
+	appResponse  string
+	appStatus    int
+	appsResponse string
+}
+
+func (suite *MarathonTestSuite) loadFile(filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return data
+}
+
+func (suite *MarathonTestSuite) exampleHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/marathon/v2/apps":
+		w.WriteHeader(http.StatusOK)
+		w.Write(suite.loadFile(suite.appsResponse))
+	case "/marathon/v2/apps/" + config.ServiceName:
+		w.WriteHeader(suite.appStatus)
+		w.Write(suite.loadFile(suite.appResponse))
+	default:
+		// the scheduler itself
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+func (suite *MarathonTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(suite.exampleHandler))
+	config.DcosURL = suite.server.URL
+	config.DcosAuthToken = "dummytoken"
+	config.ServiceName = "hello-world"
+	suite.appStatus = http.StatusOK
+	suite.appsResponse = "testdata/responses/marathon/apps.json"
+}
+
+func (suite *MarathonTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func TestMarathonTestSuite(t *testing.T) {
+	suite.Run(t, new(MarathonTestSuite))
+}
+
+func (suite *MarathonTestSuite) schedulerError() error {
+	_, err := HTTPServiceGet("v1/plans")
+	return err
+}
+
+func (suite *MarathonTestSuite) TestMarathonAppID() {
+	assert.Equal(suite.T(), "/kafka", MarathonAppID("kafka"))
+	assert.Equal(suite.T(), "/dev/kafka", MarathonAppID("/dev/kafka/"))
+}
+
+func (suite *MarathonTestSuite) TestSimilarAppIDs() {
+	appIDs := []string{"/hello-world", "/dev/hello-world", "/hello-wrld", "/kafka", "/dev/kafka-staging"}
+	assert.Equal(suite.T(), []string{"/dev/hello-world", "/hello-wrld"}, similarAppIDs("/hello-world", appIDs))
+	assert.Equal(suite.T(), []string{"/dev/kafka-staging", "/kafka"}, similarAppIDs("/prod/kafka", appIDs))
+	assert.Empty(suite.T(), similarAppIDs("/cassandra", appIDs))
+}
+
+func (suite *MarathonTestSuite) TestEditDistance() {
+	assert.Equal(suite.T(), 0, editDistance("kafka", "kafka"))
+	assert.Equal(suite.T(), 1, editDistance("kafka", "kafk"))
+	assert.Equal(suite.T(), 2, editDistance("hdfs", "hfds"))
+	assert.Equal(suite.T(), 5, editDistance("", "kafka"))
+}
+
+func (suite *MarathonTestSuite) TestServiceNotFound() {
+	config.ServiceName = "hello-world-1"
+	suite.appStatus = http.StatusNotFound
+	suite.appResponse = "testdata/responses/marathon/app-not-found.json"
+
+	expectedOutput := suite.loadFile("testdata/output/marathon-not-found.txt")
+	err := suite.schedulerError()
+	assert.Equal(suite.T(), string(expectedOutput), err.Error())
+	if assert.IsType(suite.T(), &ServiceNotFoundError{}, err) {
+		assert.Equal(suite.T(), []string{"/dev/hello-world-1", "/hello-world"}, err.(*ServiceNotFoundError).Similar)
+	}
+	assert.Equal(suite.T(), ExitCodeServiceNotFound, ExitCode(err))
+}
+
+func (suite *MarathonTestSuite) TestSchedulerDeploying() {
+	suite.appResponse = "testdata/responses/marathon/app-deploying.json"
+
+	expectedOutput := suite.loadFile("testdata/output/marathon-deploying.txt")
+	err := suite.schedulerError()
+	assert.Equal(suite.T(), string(expectedOutput), err.Error())
+	assert.Equal(suite.T(), ExitCodeSchedulerUnavailable, ExitCode(err))
+}
+
+func (suite *MarathonTestSuite) TestSchedulerCrashing() {
+	suite.appResponse = "testdata/responses/marathon/app-crashing.json"
+
+	expectedOutput := suite.loadFile("testdata/output/marathon-crashing.txt")
+	assert.Equal(suite.T(), string(expectedOutput), suite.schedulerError().Error())
+}
+
+func (suite *MarathonTestSuite) TestSchedulerHealthy() {
+	suite.appResponse = "testdata/responses/marathon/app-healthy.json"
+
+	err := suite.schedulerError()
+	assert.Contains(suite.T(), err.Error(), "The scheduler for service 'hello-world' is running according to Marathon, but the request failed.")
+	assert.Contains(suite.T(), err.Error(), "/service/hello-world/v1/plans failed: 502 Bad Gateway")
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,47 +15,59 @@ import (
 // fmt.Println(fmt.Sprintf()) to allow assertions against captured output.
 var PrintMessage = printMessage
 
-// PrintMessageAndExit is a placeholder function that wraps a call to
-// PrintMessage() before exiting to allow assertions against captured output.
+// PrintMessageAndExit is a placeholder function that prints an error message to stderr before
+// exiting with ExitCodeError, to allow assertions against captured output.
 var PrintMessageAndExit = printMessageAndExit
 
+// PrintErrorAndExit is a placeholder function that prints an error to stderr, formatted according to
+// config.ErrorFormat, before exiting with the error's exit code (see ExitCode), to allow assertions
+// against captured output.
+var PrintErrorAndExit = printErrorAndExit
+
 func printMessage(format string, a ...interface{}) (int, error) {
 	return fmt.Println(fmt.Sprintf(format, a...))
 }
 
 func printMessageAndExit(format string, a ...interface{}) (int, error) {
-	PrintMessage(format, a...)
-	os.Exit(1)
+	printErrorAndExit(errors.New(fmt.Sprintf(format, a...)))
 	return 0, nil
 }
 
+func printErrorAndExit(err error) {
+	fmt.Fprintln(ErrorOutput, FormatError(err))
+	os.Exit(ExitCode(err))
+}
+
 func printResponseError(response *http.Response) {
 	PrintMessage("HTTP %s Query for %s failed: %s",
 		response.Request.Method, response.Request.URL, response.Status)
 }
 
 func createResponseError(response *http.Response) error {
-	return fmt.Errorf("HTTP %s Query for %s failed: %s",
-		response.Request.Method, response.Request.URL, response.Status)
+	return &HTTPError{
+		Method:     response.Request.Method,
+		URL:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+	}
 }
 
 func printResponseErrorAndExit(response *http.Response) {
-	PrintMessageAndExit(createResponseError(response).Error())
+	PrintErrorAndExit(createResponseError(response))
 }
 
 func createServiceNameError() error {
 	errorString := `Could not reach the service scheduler with name '%s'.
 Did you provide the correct service name? Specify a different name with '--name=<name>'.
 Was the service recently installed or updated? It may still be initializing, wait a bit and try again.`
-	return fmt.Errorf(errorString, config.ServiceName)
+	return &SchedulerUnavailableError{Service: config.ServiceName, message: fmt.Sprintf(errorString, config.ServiceName)}
 }
 
 func printServiceNameErrorAndExit(response *http.Response) {
-	// TODO: check to see what the actual service state is
 	if config.Verbose {
 		printResponseError(response)
 	}
-	PrintMessageAndExit(createServiceNameError().Error())
+	PrintErrorAndExit(diagnoseServiceError(response))
 }
 
 // PrintJSONBytes pretty prints responseBytes assuming it is valid JSON.
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// NewServiceProxy returns a http.Handler which forwards all received requests to:
+// <config.DcosURL>/service/<config.ServiceName>/<request path>
+// The CLI's auth token and TLS settings are applied to each forwarded request. If readOnly is set,
+// any request which isn't a GET (or HEAD) is rejected with a 405 Method Not Allowed.
+func NewServiceProxy(readOnly bool) http.Handler {
+	target := createServiceURL("", "")
+	proxy := &httputil.ReverseProxy{
+		Director: func(request *http.Request) {
+			request.URL.Scheme = target.Scheme
+			request.URL.Host = target.Host
+			request.URL.Path = joinProxyPath(target.Path, request.URL.Path)
+			request.Host = target.Host
+			// never forward any credentials provided by the local client:
+			request.Header.Del("Authorization")
+			request.Header.Del("Cookie")
+		},
+		Transport: &tokenTransport{
+			transport: &http.Transport{TLSClientConfig: getTLSConfig()},
+			token:     getAuthToken(),
+		},
+	}
+	return &serviceProxy{proxy: proxy, readOnly: readOnly}
+}
+
+type serviceProxy struct {
+	proxy    *httputil.ReverseProxy
+	readOnly bool
+}
+
+func (p *serviceProxy) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if p.readOnly && request.Method != "GET" && request.Method != "HEAD" {
+		PrintMessage("%s %s: rejected (read-only)", request.Method, request.URL)
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "Only GET requests are allowed by this read-only proxy", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.Verbose {
+		PrintMessage("%s %s", request.Method, request.URL)
+	}
+	p.proxy.ServeHTTP(writer, request)
+}
+
+// joinProxyPath joins the service base path with the path of an incoming request, preserving any
+// trailing slash in the request path.
+func joinProxyPath(basePath, requestPath string) string {
+	joined := path.Join("/", basePath, requestPath)
+	if strings.HasSuffix(requestPath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// fetchCLIToken returns the DC/OS CLI's current auth token, or an empty string if none is configured.
+var fetchCLIToken = func() string {
+	return OptionalCLIConfigValue("core.dcos_acs_token")
+}
+
+// tokenTransport injects the current auth token into every request. If the cluster responds with
+// 401 Unauthorized, the token is reloaded from the DC/OS CLI (e.g. following a 'dcos auth login')
+// and the request is retried once with the new token.
+type tokenTransport struct {
+	transport http.RoundTripper
+
+	tokenMutex sync.Mutex
+	token      string
+}
+
+func (t *tokenTransport) currentToken() string {
+	t.tokenMutex.Lock()
+	defer t.tokenMutex.Unlock()
+	return t.token
+}
+
+// refreshToken reloads the token from the DC/OS CLI, returning whether a different token was found.
+func (t *tokenTransport) refreshToken(staleToken string) bool {
+	t.tokenMutex.Lock()
+	defer t.tokenMutex.Unlock()
+	if t.token != staleToken {
+		// another request already refreshed the token
+		return true
+	}
+	newToken := fetchCLIToken()
+	if len(newToken) == 0 || newToken == t.token {
+		return false
+	}
+	if config.Verbose {
+		PrintMessage("Refreshed auth token from DC/OS CLI")
+	}
+	t.token = newToken
+	config.DcosAuthToken = newToken
+	return true
+}
+
+func (t *tokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.roundTrip(request)
+	if err != nil {
+		recordAudit(request, 0)
+	} else {
+		recordAudit(request, response.StatusCode)
+	}
+	return response, err
+}
+
+func (t *tokenTransport) roundTrip(request *http.Request) (*http.Response, error) {
+	token := t.currentToken()
+	setAuthHeader(request, token)
+	response, err := t.transport.RoundTrip(request)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+	if request.Body != nil && request.GetBody == nil {
+		// can't replay the request payload: let the client see the 401
+		return response, nil
+	}
+	if !t.refreshToken(token) {
+		return response, nil
+	}
+	response.Body.Close()
+
+	retry := request.WithContext(request.Context()) // shallow copy
+	retry.Header = make(http.Header, len(request.Header))
+	for key, values := range request.Header {
+		retry.Header[key] = values
+	}
+	if request.GetBody != nil {
+		retry.Body, err = request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	setAuthHeader(retry, t.currentToken())
+	return t.transport.RoundTrip(retry)
+}
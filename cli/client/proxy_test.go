@@ -0,0 +1,162 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProxyTestSuite struct {
+	suite.Suite
+	server        *httptest.Server
+	requestMethod string
+	requestPath   string
+	requestAuth   string
+	requestBody   string
+	requestCount  int
+	// If set, requests with any other token are rejected with 401
+	acceptedToken string
+}
+
+func (suite *ProxyTestSuite) exampleHandler(w http.ResponseWriter, r *http.Request) {
+	suite.requestMethod = r.Method
+	suite.requestPath = r.URL.Path
+	suite.requestAuth = r.Header.Get("Authorization")
+	suite.requestCount++
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.requestBody = string(body)
+	if len(suite.acceptedToken) != 0 && suite.requestAuth != "token="+suite.acceptedToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"ok"}`))
+}
+
+func (suite *ProxyTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(suite.exampleHandler))
+	config.DcosURL = suite.server.URL
+	config.DcosAuthToken = "dummytoken"
+	config.ServiceName = "hello-world"
+	suite.requestMethod = ""
+	suite.requestPath = ""
+	suite.requestCount = 0
+	suite.acceptedToken = ""
+	fetchCLIToken = func() string { return "" }
+}
+
+func (suite *ProxyTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func TestProxyTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyTestSuite))
+}
+
+func (suite *ProxyTestSuite) TestJoinProxyPath() {
+	assert.Equal(suite.T(), "/service/hello-world/v1/plans", joinProxyPath("service/hello-world", "/v1/plans"))
+	assert.Equal(suite.T(), "/service/hello-world/v1/plans/", joinProxyPath("service/hello-world", "/v1/plans/"))
+	assert.Equal(suite.T(), "/service/hello-world", joinProxyPath("service/hello-world", ""))
+	assert.Equal(suite.T(), "/service/hello-world/", joinProxyPath("service/hello-world", "/"))
+}
+
+func (suite *ProxyTestSuite) TestForwardsWithToken() {
+	request := httptest.NewRequest("GET", "http://localhost:8080/v1/plans/deploy", nil)
+	request.Header.Set("Authorization", "token=local")
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(false).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), `{"message":"ok"}`, recorder.Body.String())
+	assert.Equal(suite.T(), "GET", suite.requestMethod)
+	assert.Equal(suite.T(), "/service/hello-world/v1/plans/deploy", suite.requestPath)
+	assert.Equal(suite.T(), "token=dummytoken", suite.requestAuth)
+}
+
+func (suite *ProxyTestSuite) TestForwardsPost() {
+	request := httptest.NewRequest("POST", "http://localhost:8080/v1/plans/deploy/interrupt", strings.NewReader("{}"))
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(false).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), "POST", suite.requestMethod)
+	assert.Equal(suite.T(), "/service/hello-world/v1/plans/deploy/interrupt", suite.requestPath)
+	assert.Equal(suite.T(), "{}", suite.requestBody)
+}
+
+func (suite *ProxyTestSuite) TestReadOnlyRejectsPost() {
+	request := httptest.NewRequest("POST", "http://localhost:8080/v1/plans/deploy/interrupt", nil)
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(true).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(suite.T(), "", suite.requestMethod) // never reached the server
+}
+
+func (suite *ProxyTestSuite) TestReadOnlyAllowsGet() {
+	request := httptest.NewRequest("GET", "http://localhost:8080/v1/pods", nil)
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(true).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), "/service/hello-world/v1/pods", suite.requestPath)
+}
+
+func (suite *ProxyTestSuite) TestRefreshesStaleToken() {
+	// the user has since run 'dcos auth login', so the CLI has a newer token than the one we started with:
+	suite.acceptedToken = "freshtoken"
+	fetchCLIToken = func() string { return "freshtoken" }
+	request := httptest.NewRequest("GET", "http://localhost:8080/v1/plans/deploy", nil)
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(false).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), 2, suite.requestCount)
+	assert.Equal(suite.T(), "token=freshtoken", suite.requestAuth)
+	assert.Equal(suite.T(), "freshtoken", config.DcosAuthToken)
+}
+
+func (suite *ProxyTestSuite) TestStaleTokenWithoutRefresh() {
+	suite.acceptedToken = "freshtoken"
+	fetchCLIToken = func() string { return "dummytoken" } // CLI still has the stale token
+	request := httptest.NewRequest("GET", "http://localhost:8080/v1/plans/deploy", nil)
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(false).ServeHTTP(recorder, request)
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(suite.T(), 1, suite.requestCount)
+}
+
+func (suite *ProxyTestSuite) TestStaleTokenPostNotReplayed() {
+	suite.acceptedToken = "freshtoken"
+	refreshed := false
+	fetchCLIToken = func() string {
+		refreshed = true
+		return "freshtoken"
+	}
+	request := httptest.NewRequest("POST", "http://localhost:8080/v1/plans/deploy/interrupt", strings.NewReader("{}"))
+	recorder := httptest.NewRecorder()
+
+	NewServiceProxy(false).ServeHTTP(recorder, request)
+
+	// the payload was already consumed: the client sees the 401 rather than a retry with an empty body
+	assert.Equal(suite.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(suite.T(), 1, suite.requestCount)
+	assert.Equal(suite.T(), "{}", suite.requestBody)
+	assert.False(suite.T(), refreshed)
+}
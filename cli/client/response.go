@@ -39,11 +39,9 @@ func checkHTTPResponse(response *http.Response) ([]byte, error) {
 func defaultResponseCheck(response *http.Response) error {
 	switch {
 	case response.StatusCode == http.StatusUnauthorized:
-		errorString := `Got 401 Unauthorized response from %s
-"- Bad auth token? Run 'dcos auth login' to log in.`
-		return fmt.Errorf(errorString, response.Request.URL)
+		return &AuthError{URL: response.Request.URL.String()}
 	case response.StatusCode == http.StatusInternalServerError || response.StatusCode == http.StatusBadGateway || response.StatusCode == http.StatusNotFound:
-		return createServiceNameError()
+		return diagnoseServiceError(response)
 	case response.StatusCode < 200 || response.StatusCode >= 300:
 		return createResponseError(response)
 	}
@@ -7,6 +7,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/mesosphere/dcos-commons/cli/client"
@@ -36,21 +37,39 @@ func GetArguments() []string {
 // HandleDefaultSections is a utility method to allow applications built around this library to provide
 // all of the standard subcommands of the CLI.
 func HandleDefaultSections(app *kingpin.Application) {
+	commands.HandleAuditSection(app)
 	commands.HandleConfigSection(app)
 	commands.HandleDescribe(app)
 	commands.HandleEndpointsSection(app)
+	commands.HandlePackageSection(app)
 	commands.HandlePlanSection(app)
 	commands.HandlePodsSection(app)
+	commands.HandleProxySection(app)
+	commands.HandleSchedulerSection(app)
 	commands.HandleStateSection(app)
 	commands.HandleUpdateSection(app)
 }
 
+// defaultStateDir returns the directory for local CLI state within the DC/OS CLI's configuration
+// directory, or an empty string if that can't be located.
+func defaultStateDir() string {
+	dcosDir := os.Getenv("DCOS_DIR")
+	if len(dcosDir) == 0 {
+		homeDir := os.Getenv("HOME")
+		if len(homeDir) == 0 {
+			return ""
+		}
+		dcosDir = filepath.Join(homeDir, ".dcos")
+	}
+	return filepath.Join(dcosDir, "service-cli")
+}
+
 // New instantiates a new kingpin.Application and returns a reference to it.
 // This contains basic flags that are universally applicable, e.g. --name.
 func New() *kingpin.Application {
 	modName, err := GetModuleName()
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	config.ModuleName = modName
 	app := kingpin.New(fmt.Sprintf("dcos %s", config.ModuleName), "")
@@ -66,6 +85,10 @@ func New() *kingpin.Application {
 		return nil
 	}).Bool()
 
+	app.Flag("error-format", "Format of errors printed to stderr: text, or json for scripts").Default(client.ErrorFormatText).EnumVar(&config.ErrorFormat, client.ErrorFormatText, client.ErrorFormatJSON)
+
+	app.Flag("non-interactive", "Skip confirmation prompts for destructive commands, as if --yes were provided").Envar("DCOS_SERVICE_CLI_NON_INTERACTIVE").BoolVar(&config.NonInteractive)
+
 	app.Flag("force-insecure", "Allow unverified TLS certificates when querying service").BoolVar(&config.TLSForceInsecure)
 
 	// Overrides of data that we fetch from DC/OS CLI:
@@ -84,5 +107,8 @@ func New() *kingpin.Application {
 	}
 	app.Flag("name", "Name of the service instance to query").Default(serviceName).StringVar(&config.ServiceName)
 
+	app.Flag("audit-collector-url", "HTTP endpoint to forward audit log entries to as JSON").Envar("DCOS_SERVICE_CLI_AUDIT_COLLECTOR_URL").PlaceHolder("URL").StringVar(&config.AuditCollectorURL)
+	app.Flag("state-dir", "Directory for local state such as plan history").Hidden().Default(defaultStateDir()).StringVar(&config.StateDir)
+
 	return app
 }
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type auditHandler struct {
+	Service string
+	Since   string
+	Until   string
+	RawJSON bool
+}
+
+// parseAuditTime parses a time range flag, which may be a duration before now (e.g. "24h"), an
+// RFC3339 timestamp, or a date.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid time '%s': expected a duration (e.g. 24h), an RFC3339 timestamp or a date (YYYY-MM-DD)", value)
+}
+
+// filterAuditEntries returns the entries for the service (or all services if empty) within the time
+// range, where zero times are unbounded.
+func filterAuditEntries(entries []client.AuditEntry, service string, since, until time.Time) []client.AuditEntry {
+	filtered := make([]client.AuditEntry, 0)
+	for _, entry := range entries {
+		if len(service) > 0 && strings.Trim(entry.Service, "/") != strings.Trim(service, "/") {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && entry.Time.After(until) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+func toAuditTable(entries []client.AuditEntry) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "TIME\tUSER\tCLUSTER\tSERVICE\tREQUEST\tSTATUS\tCOMMAND\n")
+	for _, entry := range entries {
+		status := "-"
+		if entry.Status != 0 {
+			status = fmt.Sprintf("%d", entry.Status)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s %s\t%s\t%s\n", entry.Time.UTC().Format(time.RFC3339),
+			valueOrNone(entry.User), entry.Cluster, entry.Service, entry.Method, entry.Endpoint, status, entry.Command)
+	}
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (cmd *auditHandler) handleAudit(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	now := time.Now()
+	var since, until time.Time
+	var err error
+	if len(cmd.Since) > 0 {
+		if since, err = parseAuditTime(cmd.Since, now); err != nil {
+			client.PrintErrorAndExit(err)
+			return nil
+		}
+	}
+	if len(cmd.Until) > 0 {
+		if until, err = parseAuditTime(cmd.Until, now); err != nil {
+			client.PrintErrorAndExit(err)
+			return nil
+		}
+	}
+	entries, err := client.ReadAuditLog()
+	if err != nil {
+		client.PrintMessageAndExit(fmt.Sprintf("Failed to read audit log: %s", err))
+		return nil
+	}
+	entries = filterAuditEntries(entries, cmd.Service, since, until)
+	if cmd.RawJSON {
+		entriesBytes, err := json.Marshal(entries)
+		if err != nil {
+			client.PrintErrorAndExit(err)
+			return nil
+		}
+		client.PrintJSONBytes(entriesBytes)
+		return nil
+	}
+	if len(entries) == 0 {
+		client.PrintMessage("No matching entries in audit log %s.", valueOrNone(client.AuditLogPath()))
+		return nil
+	}
+	client.PrintMessage("%s", toAuditTable(entries))
+	return nil
+}
+
+// HandleAuditSection adds the audit subcommand to the passed in kingpin.Application.
+func HandleAuditSection(app *kingpin.Application) {
+	cmd := &auditHandler{}
+	audit := app.Command("audit", "View the local log of mutating requests made by this CLI").Action(cmd.handleAudit)
+	audit.Flag("service", "Only show requests for the service with this name").StringVar(&cmd.Service)
+	audit.Flag("since", "Only show requests after this time: a duration before now (e.g. 24h), an RFC3339 timestamp or a date").StringVar(&cmd.Since)
+	audit.Flag("until", "Only show requests before this time: a duration before now (e.g. 1h), an RFC3339 timestamp or a date").StringVar(&cmd.Until)
+	audit.Flag("json", "Show raw JSON entries instead of a table").BoolVar(&cmd.RawJSON)
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func auditTestEntries() []client.AuditEntry {
+	return []client.AuditEntry{
+		{Time: time.Date(2017, 7, 4, 10, 0, 0, 0, time.UTC), Cluster: "cluster.example.com", Service: "kafka", User: "ops-alice",
+			Command: "kafka pods replace kafka-0", Method: "POST", Endpoint: "/service/kafka/v1/pods/kafka-0/replace", Status: 200},
+		{Time: time.Date(2017, 7, 5, 12, 0, 0, 0, time.UTC), Cluster: "cluster.example.com", Service: "/dev/hdfs",
+			Command: "hdfs plan stop deploy", Method: "POST", Endpoint: "/service/dev/hdfs/v1/plans/deploy/stop"},
+		{Time: time.Date(2017, 7, 5, 18, 0, 0, 0, time.UTC), Cluster: "cluster.example.com", Service: "kafka", User: "ops-bob",
+			Command: "kafka topic delete payments", Method: "DELETE", Endpoint: "/service/kafka/v1/topics/payments", Status: 500},
+	}
+}
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2017, 7, 5, 19, 0, 0, 0, time.UTC)
+	parsed, err := parseAuditTime("24h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2017, 7, 4, 19, 0, 0, 0, time.UTC), parsed)
+	parsed, err = parseAuditTime("2017-07-05T12:00:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2017, 7, 5, 12, 0, 0, 0, time.UTC), parsed)
+	_, err = parseAuditTime("yesterday", now)
+	assert.EqualError(t, err, "Invalid time 'yesterday': expected a duration (e.g. 24h), an RFC3339 timestamp or a date (YYYY-MM-DD)")
+}
+
+func TestFilterAuditEntries(t *testing.T) {
+	entries := auditTestEntries()
+	assert.Equal(t, 3, len(filterAuditEntries(entries, "", time.Time{}, time.Time{})))
+
+	filtered := filterAuditEntries(entries, "kafka", time.Time{}, time.Time{})
+	assert.Equal(t, 2, len(filtered))
+	assert.Equal(t, "ops-alice", filtered[0].User)
+
+	filtered = filterAuditEntries(entries, "dev/hdfs", time.Time{}, time.Time{})
+	assert.Equal(t, 1, len(filtered))
+
+	filtered = filterAuditEntries(entries, "", time.Date(2017, 7, 5, 0, 0, 0, 0, time.UTC), time.Date(2017, 7, 5, 15, 0, 0, 0, time.UTC))
+	assert.Equal(t, 1, len(filtered))
+	assert.Equal(t, "/dev/hdfs", filtered[0].Service)
+}
+
+func TestAuditTable(t *testing.T) {
+	expectedOutput := `TIME                  USER       CLUSTER              SERVICE    REQUEST                                      STATUS  COMMAND
+2017-07-04T10:00:00Z  ops-alice  cluster.example.com  kafka      POST /service/kafka/v1/pods/kafka-0/replace  200     kafka pods replace kafka-0
+2017-07-05T12:00:00Z  <none>     cluster.example.com  /dev/hdfs  POST /service/dev/hdfs/v1/plans/deploy/stop  -       hdfs plan stop deploy
+2017-07-05T18:00:00Z  ops-bob    cluster.example.com  kafka      DELETE /service/kafka/v1/topics/payments     500     kafka topic delete payments`
+	assert.Equal(t, expectedOutput, toAuditTable(auditTestEntries()))
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// defaultComparisonIgnoredPaths are the fields which are expected to differ between instances of a
+// package: the service name in the package options, and in the scheduler's target configuration.
+var defaultComparisonIgnoredPaths = []string{"service.name", "name"}
+
+// getTargetConfig returns the scheduler's target configuration for the named service.
+func getTargetConfig(serviceName string) (map[string]interface{}, error) {
+	// service queries are always made against config.ServiceName:
+	originalServiceName := config.ServiceName
+	config.ServiceName = serviceName
+	defer func() { config.ServiceName = originalServiceName }()
+
+	responseBytes, err := client.HTTPServiceGet("v1/configurations/target")
+	if err != nil {
+		return nil, err
+	}
+	return client.UnmarshalJSON(responseBytes)
+}
+
+// toServiceComparison renders the differences between the package versions and options of two
+// services, where changes are shown going from this service to the other service.
+func toServiceComparison(serviceName, otherName string, description, otherDescription *packageDescription, ignoredPaths []string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Comparing service %s => %s\n", serviceName, otherName)
+	if description.Package.Name != otherDescription.Package.Name {
+		fmt.Fprintf(&buf, "Package name: %s => %s\n", description.Package.Name, otherDescription.Package.Name)
+	}
+	if description.Package.Version == otherDescription.Package.Version {
+		fmt.Fprintf(&buf, "Package version: %s (same)\n", description.Package.Version)
+	} else {
+		fmt.Fprintf(&buf, "Package version: %s => %s\n", description.Package.Version, otherDescription.Package.Version)
+	}
+	if description.ResolvedOptions == nil || otherDescription.ResolvedOptions == nil {
+		buf.WriteString("Package options are not available: comparing options is only available for packages installed with Enterprise DC/OS 1.10 or newer.")
+	} else {
+		buf.WriteString(toDiffString("Package options differences", description.ResolvedOptions, otherDescription.ResolvedOptions, ignoredPaths))
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// describeService returns the Cosmos description of the named service's package, reporting errors
+// such as the service not being installed against that service rather than config.ServiceName.
+func describeService(serviceName string) *packageDescription {
+	originalServiceName := config.ServiceName
+	config.ServiceName = serviceName
+	defer func() { config.ServiceName = originalServiceName }()
+	return describePackage(serviceName)
+}
+
+// compareServices prints how the other service's package version and options (and optionally its
+// scheduler's target configuration) differ from those of this service.
+func compareServices(otherName string, ignoredPaths []string, schedulerConfig bool) {
+	description := describePackage(config.ServiceName)
+	otherDescription := describeService(otherName)
+	client.PrintMessage("%s", toServiceComparison(config.ServiceName, otherName, description, otherDescription, ignoredPaths))
+	if !schedulerConfig {
+		return
+	}
+	targetConfig, err := getTargetConfig(config.ServiceName)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return
+	}
+	otherTargetConfig, err := getTargetConfig(otherName)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return
+	}
+	client.PrintMessage("%s", toDiffString("Scheduler target configuration differences", targetConfig, otherTargetConfig, ignoredPaths))
+}
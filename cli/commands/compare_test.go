@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadDescription(t *testing.T, filename string) *packageDescription {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var description packageDescription
+	if err := json.Unmarshal(data, &description); err != nil {
+		t.Fatal(err)
+	}
+	return &description
+}
+
+func TestServiceComparison(t *testing.T) {
+	description := loadDescription(t, "testdata/responses/cosmos/1.10/enterprise/describe.json")
+	otherDescription := loadDescription(t, "testdata/responses/cosmos/1.10/enterprise/describe.json")
+	otherDescription.Package.Version = "v1.1"
+	otherDescription.ResolvedOptions["service"].(map[string]interface{})["name"] = "hello-world-prod"
+	otherDescription.ResolvedOptions["hello"].(map[string]interface{})["count"] = 3
+
+	expectedOutput := `Comparing service hello-world => hello-world-prod
+Package version: v1.0 => v1.1
+Package options differences:
+- hello.count: 1
++ hello.count: 3`
+	assert.Equal(t, expectedOutput, toServiceComparison("hello-world", "hello-world-prod", description, otherDescription, defaultComparisonIgnoredPaths))
+
+	output := toServiceComparison("hello-world", "hello-world-prod", description, otherDescription, nil)
+	assert.Contains(t, output, "- service.name: \"hello-world\"\n+ service.name: \"hello-world-prod\"")
+}
+
+func TestServiceComparisonSame(t *testing.T) {
+	description := loadDescription(t, "testdata/responses/cosmos/1.10/enterprise/describe.json")
+
+	expectedOutput := `Comparing service hello-world => hello-world-2
+Package version: v1.0 (same)
+Package options differences: no differences`
+	assert.Equal(t, expectedOutput, toServiceComparison("hello-world", "hello-world-2", description, description, defaultComparisonIgnoredPaths))
+}
+
+func TestServiceComparisonNoOptions(t *testing.T) {
+	description := loadDescription(t, "testdata/responses/cosmos/1.10/enterprise/describe.json")
+	openDescription := loadDescription(t, "testdata/responses/cosmos/1.10/open/describe.json")
+
+	output := toServiceComparison("hello-world", "hello-world-2", description, openDescription, defaultComparisonIgnoredPaths)
+	assert.Contains(t, output, "Package options are not available")
+}
+
+func TestCompareMissingOtherService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var request describeRequest
+		json.Unmarshal(body, &request)
+		if request.AppID != "hello-world" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"type": "MarathonAppNotFound", "message": "Unable to locate service", "data": {}}`))
+			return
+		}
+		data, _ := ioutil.ReadFile("testdata/responses/cosmos/1.10/enterprise/describe.json")
+		w.Write(data)
+	}))
+	defer server.Close()
+	dcosURL, serviceName := config.DcosURL, config.ServiceName
+	printMessage, printMessageAndExit, printErrorAndExit := client.PrintMessage, client.PrintMessageAndExit, client.PrintErrorAndExit
+	defer func() {
+		config.DcosURL, config.ServiceName = dcosURL, serviceName
+		client.PrintMessage, client.PrintMessageAndExit, client.PrintErrorAndExit = printMessage, printMessageAndExit, printErrorAndExit
+	}()
+	config.DcosURL = server.URL
+	config.ServiceName = "hello-world"
+	errs := make([]error, 0)
+	client.PrintErrorAndExit = func(err error) { errs = append(errs, err) }
+	discard := func(format string, a ...interface{}) (int, error) { return 0, nil }
+	client.PrintMessage, client.PrintMessageAndExit = discard, discard
+
+	compareServices("hello-world-2", defaultComparisonIgnoredPaths, false)
+	if assert.NotEmpty(t, errs) && assert.IsType(t, &client.ServiceNotFoundError{}, errs[0]) {
+		assert.Equal(t, "hello-world-2", errs[0].(*client.ServiceNotFoundError).Service)
+		assert.Contains(t, errs[0].Error(), "'hello-world-2'")
+	}
+	assert.Equal(t, "hello-world", config.ServiceName)
+}
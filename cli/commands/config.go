@@ -15,7 +15,7 @@ func (cmd *configHandler) handleList(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/configurations")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -25,7 +25,7 @@ func (cmd *configHandler) handleShow(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet(fmt.Sprintf("v1/configurations/%s", cmd.ShowID))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -35,7 +35,7 @@ func (cmd *configHandler) handleTarget(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/configurations/target")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -45,7 +45,7 @@ func (cmd *configHandler) handleTargetID(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/configurations/targetId")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// flattenJSON converts a tree of unmarshaled JSON into a map of dotted paths to leaf values, e.g.
+// {"hello": {"count": 3}, "ports": [80]} => {"hello.count": 3, "ports[0]": 80}
+func flattenJSON(prefix string, value interface{}, flattened map[string]interface{}) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if len(typedValue) == 0 && len(prefix) > 0 {
+			flattened[prefix] = typedValue
+		}
+		for key, child := range typedValue {
+			childPrefix := key
+			if len(prefix) > 0 {
+				childPrefix = prefix + "." + key
+			}
+			flattenJSON(childPrefix, child, flattened)
+		}
+	case []interface{}:
+		if len(typedValue) == 0 {
+			flattened[prefix] = typedValue
+		}
+		for i, child := range typedValue {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), child, flattened)
+		}
+	default:
+		flattened[prefix] = typedValue
+	}
+}
+
+func formatDiffValue(value interface{}) string {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(valueBytes)
+}
+
+// isIgnoredPath returns whether the path is one of the ignored paths, or is nested within one.
+func isIgnoredPath(path string, ignoredPaths []string) bool {
+	for _, ignored := range ignoredPaths {
+		if path == ignored || strings.HasPrefix(path, ignored+".") || strings.HasPrefix(path, ignored+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffJSON returns a sorted list of the differences between two trees of unmarshaled JSON, in the form:
+// - hello.count: 3
+// + hello.count: 5
+// Any paths listed in ignoredPaths (and their children) are omitted.
+func diffJSON(oldValue, newValue interface{}, ignoredPaths []string) []string {
+	oldFlattened := make(map[string]interface{})
+	flattenJSON("", oldValue, oldFlattened)
+	newFlattened := make(map[string]interface{})
+	flattenJSON("", newValue, newFlattened)
+
+	paths := make([]string, 0, len(oldFlattened)+len(newFlattened))
+	for path := range oldFlattened {
+		paths = append(paths, path)
+	}
+	for path := range newFlattened {
+		if _, ok := oldFlattened[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	diff := make([]string, 0)
+	for _, path := range paths {
+		if isIgnoredPath(path, ignoredPaths) {
+			continue
+		}
+		oldLeaf, oldOk := oldFlattened[path]
+		newLeaf, newOk := newFlattened[path]
+		oldString := formatDiffValue(oldLeaf)
+		newString := formatDiffValue(newLeaf)
+		if oldOk && newOk && oldString == newString {
+			continue
+		}
+		if oldOk {
+			diff = append(diff, fmt.Sprintf("- %s: %s", path, oldString))
+		}
+		if newOk {
+			diff = append(diff, fmt.Sprintf("+ %s: %s", path, newString))
+		}
+	}
+	return diff
+}
+
+// toDiffString renders the differences between two trees of unmarshaled JSON under a title, or
+// notes that there are no differences.
+func toDiffString(title string, oldValue, newValue interface{}, ignoredPaths []string) string {
+	diff := diffJSON(oldValue, newValue, ignoredPaths)
+	if len(diff) == 0 {
+		return fmt.Sprintf("%s: no differences", title)
+	}
+	return fmt.Sprintf("%s:\n%s", title, strings.Join(diff, "\n"))
+}
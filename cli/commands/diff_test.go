@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func unmarshal(t *testing.T, jsonString string) map[string]interface{} {
+	result, err := client.UnmarshalJSON([]byte(jsonString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestDiffJSON(t *testing.T) {
+	oldJSON := unmarshal(t, `{"service": {"name": "kafka-staging"}, "brokers": {"count": 3, "cpus": 1}, "ports": [9092], "removed": true}`)
+	newJSON := unmarshal(t, `{"service": {"name": "kafka-prod"}, "brokers": {"count": 5, "cpus": 1}, "ports": [9092, 9093], "added": {}}`)
+
+	expected := []string{
+		"+ added: {}",
+		"- brokers.count: 3",
+		"+ brokers.count: 5",
+		"+ ports[1]: 9093",
+		"- removed: true",
+		"- service.name: \"kafka-staging\"",
+		"+ service.name: \"kafka-prod\"",
+	}
+	assert.Equal(t, expected, diffJSON(oldJSON, newJSON, nil))
+
+	expected = []string{
+		"+ added: {}",
+		"- brokers.count: 3",
+		"+ brokers.count: 5",
+		"- removed: true",
+	}
+	assert.Equal(t, expected, diffJSON(oldJSON, newJSON, []string{"service.name", "ports"}))
+}
+
+func TestDiffString(t *testing.T) {
+	oldJSON := unmarshal(t, `{"brokers": {"count": 3}}`)
+	assert.Equal(t, "Options: no differences", toDiffString("Options", oldJSON, oldJSON, nil))
+
+	newJSON := unmarshal(t, `{"brokers": {"count": 5}}`)
+	assert.Equal(t, "Options:\n- brokers.count: 3\n+ brokers.count: 5", toDiffString("Options", oldJSON, newJSON, nil))
+}
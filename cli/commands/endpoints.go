@@ -16,7 +16,7 @@ func (cmd *endpointsHandler) handleEndpoints(c *kingpin.ParseContext) error {
 	}
 	responseBytes, err := client.HTTPServiceGet(path)
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	if len(cmd.Name) == 0 {
 		// Root endpoint: Always produce JSON
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonType returns the name of the JSON type of a value produced by json.Unmarshal.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// lookupOption returns the value at the dotted path within the options, and whether it was present.
+func lookupOption(options map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	current := options
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := current[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = child
+	}
+	value, ok := current[keys[len(keys)-1]]
+	return value, ok
+}
+
+// parseOptionValue converts the value of a --set flag to match the type of the current value. Strings
+// are taken verbatim, while other values are parsed as JSON, falling back to a string for new options.
+func parseOptionValue(path, rawValue string, currentValue interface{}, exists bool) (interface{}, error) {
+	if _, ok := currentValue.(string); ok {
+		return rawValue, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(rawValue), &value); err != nil {
+		if exists && currentValue != nil {
+			return nil, fmt.Errorf("Value '%s' for %s is not a valid %s", rawValue, path, jsonType(currentValue))
+		}
+		return rawValue, nil
+	}
+	if exists && currentValue != nil && value != nil && jsonType(value) != jsonType(currentValue) {
+		return nil, fmt.Errorf("Value '%s' for %s is a %s, expected a %s", rawValue, path, jsonType(value), jsonType(currentValue))
+	}
+	return value, nil
+}
+
+// setOption applies a "path.to.field=value" assignment to the options, creating any missing parent
+// objects along the path.
+func setOption(options map[string]interface{}, assignment string) error {
+	separator := strings.Index(assignment, "=")
+	if separator <= 0 {
+		return fmt.Errorf("Invalid --set value '%s': expected path.to.field=value", assignment)
+	}
+	path := assignment[:separator]
+	currentValue, exists := lookupOption(options, path)
+	value, err := parseOptionValue(path, assignment[separator+1:], currentValue, exists)
+	if err != nil {
+		return err
+	}
+
+	keys := strings.Split(path, ".")
+	current := options
+	for i, key := range keys[:len(keys)-1] {
+		child, ok := current[key]
+		if !ok {
+			child = make(map[string]interface{})
+			current[key] = child
+		}
+		childMap, ok := child.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Cannot set %s: %s is a %s, not an object", path, strings.Join(keys[:i+1], "."), jsonType(child))
+		}
+		current = childMap
+	}
+	current[keys[len(keys)-1]] = value
+	return nil
+}
+
+// unsetOption removes the option at the dotted path.
+func unsetOption(options map[string]interface{}, path string) error {
+	if _, exists := lookupOption(options, path); !exists {
+		return fmt.Errorf("Cannot unset %s: option is not set", path)
+	}
+	keys := strings.Split(path, ".")
+	parent := options
+	for _, key := range keys[:len(keys)-1] {
+		parent = parent[key].(map[string]interface{})
+	}
+	delete(parent, keys[len(keys)-1])
+	return nil
+}
+
+// mergeOptions recursively copies the overrides into the options, replacing any non-object values.
+func mergeOptions(options, overrides map[string]interface{}) {
+	for key, override := range overrides {
+		overrideMap, overrideIsMap := override.(map[string]interface{})
+		existingMap, existingIsMap := options[key].(map[string]interface{})
+		if overrideIsMap && existingIsMap {
+			mergeOptions(existingMap, overrideMap)
+		} else {
+			options[key] = override
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOption(t *testing.T) {
+	options := map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 3.0, "name": "kafka", "enabled": true},
+	}
+	assert.NoError(t, setOption(options, "brokers.count=5"))
+	assert.NoError(t, setOption(options, "brokers.name=12"))
+	assert.NoError(t, setOption(options, "brokers.enabled=false"))
+	assert.NoError(t, setOption(options, "service.security.kerberos.enabled=true"))
+	assert.NoError(t, setOption(options, "service.user=nobody"))
+	assert.NoError(t, setOption(options, "service.ports=[9092,9093]"))
+	assert.Equal(t, map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 5.0, "name": "12", "enabled": false},
+		"service": map[string]interface{}{
+			"security": map[string]interface{}{"kerberos": map[string]interface{}{"enabled": true}},
+			"user":     "nobody",
+			"ports":    []interface{}{9092.0, 9093.0},
+		},
+	}, options)
+}
+
+func TestSetOptionErrors(t *testing.T) {
+	options := map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 3.0, "enabled": true},
+	}
+	assert.EqualError(t, setOption(options, "brokers.count"), "Invalid --set value 'brokers.count': expected path.to.field=value")
+	assert.EqualError(t, setOption(options, "=5"), "Invalid --set value '=5': expected path.to.field=value")
+	assert.EqualError(t, setOption(options, "brokers.count=many"), "Value 'many' for brokers.count is not a valid number")
+	assert.EqualError(t, setOption(options, "brokers.enabled=1"), "Value '1' for brokers.enabled is a number, expected a boolean")
+	assert.EqualError(t, setOption(options, "brokers.count.max=5"), "Cannot set brokers.count.max: brokers.count is a number, not an object")
+}
+
+func TestUnsetOption(t *testing.T) {
+	options := map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 3.0, "name": "kafka"},
+	}
+	assert.NoError(t, unsetOption(options, "brokers.name"))
+	assert.EqualError(t, unsetOption(options, "brokers.name"), "Cannot unset brokers.name: option is not set")
+	assert.EqualError(t, unsetOption(options, "service.user"), "Cannot unset service.user: option is not set")
+	assert.Equal(t, map[string]interface{}{"brokers": map[string]interface{}{"count": 3.0}}, options)
+}
+
+func TestMergeOptions(t *testing.T) {
+	options := map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 3.0, "name": "kafka"},
+		"service": map[string]interface{}{"user": "root"},
+	}
+	mergeOptions(options, map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 5.0},
+		"service": "replaced",
+	})
+	assert.Equal(t, map[string]interface{}{
+		"brokers": map[string]interface{}{"count": 5.0, "name": "kafka"},
+		"service": "replaced",
+	}, options)
+}
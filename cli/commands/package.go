@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type installRequest struct {
+	PackageName    string                 `json:"packageName"`
+	PackageVersion string                 `json:"packageVersion,omitempty"`
+	OptionsJSON    map[string]interface{} `json:"options,omitempty"`
+}
+
+// installResponse covers both versions of the install response: v2 adds postInstallNotes.
+type installResponse struct {
+	PackageName      string `json:"packageName"`
+	PackageVersion   string `json:"packageVersion"`
+	AppID            string `json:"appId"`
+	PostInstallNotes string `json:"postInstallNotes"`
+}
+
+type uninstallRequest struct {
+	PackageName string `json:"packageName"`
+	AppID       string `json:"appId"`
+}
+
+type uninstallResponse struct {
+	Results []struct {
+		PackageName        string `json:"packageName"`
+		PackageVersion     string `json:"packageVersion"`
+		AppID              string `json:"appId"`
+		PostUninstallNotes string `json:"postUninstallNotes"`
+	} `json:"results"`
+}
+
+type listRequest struct {
+	PackageName string `json:"packageName"`
+}
+
+type listResponse struct {
+	Packages []packageInstance `json:"packages"`
+}
+
+type packageInstance struct {
+	AppID              string `json:"appId"`
+	PackageInformation struct {
+		PackageDefinition struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"packageDefinition"`
+	} `json:"packageInformation"`
+}
+
+type packageHandler struct {
+	PackageName    string
+	OptionsFile    string
+	PackageVersion string
+	Wait           bool
+	WaitTimeout    time.Duration
+	Yes            bool
+	RawJSON        bool
+}
+
+// installOptions returns the options to install the service with, setting service.name to the
+// name of the service if the options don't already specify a name.
+func installOptions(optionsFile string) (map[string]interface{}, error) {
+	options := make(map[string]interface{})
+	if len(optionsFile) > 0 {
+		var err error
+		if options, err = readOptionsFile(optionsFile); err != nil {
+			return nil, err
+		}
+	}
+	if name, ok := lookupOption(options, "service.name"); ok {
+		if name != config.ServiceName && name != strings.Trim(config.ServiceName, "/") {
+			return nil, fmt.Errorf("service.name '%v' in options doesn't match the service name '%s'. Specify the name with '--name=<name>'.",
+				name, config.ServiceName)
+		}
+		return options, nil
+	}
+	if err := setOption(options, "service.name="+strings.Trim(config.ServiceName, "/")); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func doInstall(packageName, optionsFile, packageVersion string) bool {
+	options, err := installOptions(optionsFile)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return false
+	}
+	requestContent, _ := json.Marshal(installRequest{PackageName: packageName, PackageVersion: packageVersion, OptionsJSON: options})
+	responseBytes, version, err := client.HTTPCosmosPackagePostJSON("install", string(requestContent))
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return false
+	}
+	var response installResponse
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		reportErrorAndExit(err, responseBytes)
+		return false
+	}
+	if config.Verbose {
+		client.PrintMessage("Cosmos install response version: %s", version)
+	}
+	client.PrintMessage("Installing %s %s as service %s.", response.PackageName, response.PackageVersion, response.AppID)
+	if len(response.PostInstallNotes) > 0 {
+		client.PrintMessage("%s", response.PostInstallNotes)
+	}
+	return true
+}
+
+// waitForSchedulerApp waits for the Marathon app of the service's scheduler to be deployed.
+func (w *updateWaiter) waitForSchedulerApp() {
+	appID := client.MarathonAppID(config.ServiceName)
+	for {
+		app, err := client.GetMarathonApp(appID)
+		switch {
+		case err != nil:
+			if config.Verbose {
+				client.PrintMessage("Failed to retrieve Marathon app %s: %s", appID, err)
+			}
+		case app == nil:
+			w.progress(fmt.Sprintf("Waiting for Marathon app %s to be created...", appID))
+		case len(app.Deployments) > 0 || app.TasksRunning == 0:
+			w.progress(fmt.Sprintf("Waiting for the scheduler to be deployed by Marathon (%d of %d instances running)...",
+				app.TasksRunning, app.Instances))
+		default:
+			w.progress("Scheduler is running, waiting for the scheduler API to become available...")
+			return
+		}
+		w.sleep(fmt.Sprintf("Marathon app %s", appID))
+	}
+}
+
+func waitForInstall(timeout time.Duration) {
+	waiter := newUpdateWaiter(timeout)
+	waiter.waitForSchedulerApp()
+	if err := waiter.waitForPlan("deploy"); err != nil {
+		client.PrintMessageAndExit(fmt.Sprintf("Install failed: %s.", err))
+		return
+	}
+	client.PrintMessage("Install complete.")
+}
+
+func (cmd *packageHandler) handleInstall(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	if !doInstall(cmd.PackageName, cmd.OptionsFile, cmd.PackageVersion) {
+		return nil
+	}
+	if cmd.Wait {
+		waitForInstall(cmd.WaitTimeout)
+	}
+	return nil
+}
+
+func doUninstall(packageName string, yes bool) bool {
+	impact := fmt.Sprintf("Uninstalling service '%s' will kill all of its tasks and delete all of its data.", config.ServiceName)
+	if !client.ConfirmWithName(impact, strings.Trim(config.ServiceName, "/"), yes) {
+		client.PrintMessageAndExit("Uninstall cancelled.")
+		return false
+	}
+	requestContent, _ := json.Marshal(uninstallRequest{PackageName: packageName, AppID: config.ServiceName})
+	responseBytes, _, err := client.HTTPCosmosPackagePostJSON("uninstall", string(requestContent))
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return false
+	}
+	var response uninstallResponse
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		reportErrorAndExit(err, responseBytes)
+		return false
+	}
+	for _, result := range response.Results {
+		client.PrintMessage("Uninstalling %s %s service %s.", result.PackageName, result.PackageVersion, result.AppID)
+		if len(result.PostUninstallNotes) > 0 {
+			client.PrintMessage("%s", result.PostUninstallNotes)
+		}
+	}
+	return true
+}
+
+// waitForUninstall follows the scheduler's teardown of the service's tasks and resources, which it
+// reports as its deploy plan, until the scheduler's Marathon app has been removed.
+func waitForUninstall(timeout time.Duration) {
+	waiter := newUpdateWaiter(timeout)
+	appID := client.MarathonAppID(config.ServiceName)
+	for {
+		app, err := client.GetMarathonApp(appID)
+		switch {
+		case err != nil:
+			if config.Verbose {
+				client.PrintMessage("Failed to retrieve Marathon app %s: %s", appID, err)
+			}
+		case app == nil:
+			client.PrintMessage("Uninstall complete.")
+			return
+		default:
+			plan, _, err := getPlan("deploy")
+			if err == nil {
+				waiter.planProgress("deploy", plan)
+			} else {
+				waiter.progress("Waiting for the scheduler to tear down the service...")
+			}
+		}
+		waiter.sleep(fmt.Sprintf("service %s to be uninstalled", config.ServiceName))
+	}
+}
+
+func (cmd *packageHandler) handleUninstall(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	if !doUninstall(cmd.PackageName, cmd.Yes) {
+		return nil
+	}
+	if cmd.Wait {
+		waitForUninstall(cmd.WaitTimeout)
+	}
+	return nil
+}
+
+func toInstancesTable(instances []packageInstance) string {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].AppID < instances[j].AppID })
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "NAME\tPACKAGE\tVERSION\n")
+	for _, instance := range instances {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", strings.TrimLeft(instance.AppID, "/"),
+			instance.PackageInformation.PackageDefinition.Name, instance.PackageInformation.PackageDefinition.Version)
+	}
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (cmd *packageHandler) handleInstances(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	requestContent, _ := json.Marshal(listRequest{PackageName: cmd.PackageName})
+	responseBytes, _, err := client.HTTPCosmosPackagePostJSON("list", string(requestContent))
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return nil
+	}
+	if cmd.RawJSON {
+		client.PrintJSONBytes(responseBytes)
+		return nil
+	}
+	var response listResponse
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		reportErrorAndExit(err, responseBytes)
+		return nil
+	}
+	if len(response.Packages) == 0 {
+		client.PrintMessage("No instances of package %s are installed.", cmd.PackageName)
+		return nil
+	}
+	client.PrintMessage("%s", toInstancesTable(response.Packages))
+	return nil
+}
+
+// HandlePackageSection adds the install, uninstall and instances subcommands to the passed in
+// kingpin.Application.
+func HandlePackageSection(app *kingpin.Application) {
+	cmd := &packageHandler{}
+
+	install := app.Command("install", "Install this package as a new DC/OS service with the provided name").Action(cmd.handleInstall)
+	install.Flag("options", "Path to a JSON file that contains customized package installation options, or '-' to read from stdin").StringVar(&cmd.OptionsFile)
+	install.Flag("package-version", "The package version to install, instead of the latest").StringVar(&cmd.PackageVersion)
+	install.Flag("wait", "Wait for the scheduler to be deployed and for the deploy plan to complete, exiting non-zero if it fails").BoolVar(&cmd.Wait)
+	install.Flag("timeout", "Maximum duration to wait with --wait, or zero to wait indefinitely").Default("0s").DurationVar(&cmd.WaitTimeout)
+	install.Flag("package-name", "Name of the package to install").Default(config.ModuleName).StringVar(&cmd.PackageName)
+
+	uninstall := app.Command("uninstall", "Uninstall this DC/OS service, deleting all of its tasks and data").Action(cmd.handleUninstall)
+	uninstall.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
+	uninstall.Flag("wait", "Wait for the scheduler to tear down the service and be removed from Marathon").BoolVar(&cmd.Wait)
+	uninstall.Flag("timeout", "Maximum duration to wait with --wait, or zero to wait indefinitely").Default("0s").DurationVar(&cmd.WaitTimeout)
+	uninstall.Flag("package-name", "Name of the package to uninstall").Default(config.ModuleName).StringVar(&cmd.PackageName)
+
+	instances := app.Command("instances", "List all installed instances of this package and their versions").Action(cmd.handleInstances)
+	instances.Flag("json", "Show raw JSON response instead of a table").BoolVar(&cmd.RawJSON)
+	instances.Flag("package-name", "Name of the package to list instances of").Default(config.ModuleName).StringVar(&cmd.PackageName)
+}
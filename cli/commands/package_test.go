@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PackageTestSuite struct {
+	suite.Suite
+	server         *httptest.Server
+	requestPath    string
+	requestBody    []byte
+	acceptHeader   string
+	responseBody   []byte
+	contentType    string
+	capturedOutput bytes.Buffer
+}
+
+func (suite *PackageTestSuite) printRecorder(format string, a ...interface{}) (n int, err error) {
+	suite.capturedOutput.WriteString(fmt.Sprintf(format+"\n", a...))
+	return 0, nil
+}
+
+func (suite *PackageTestSuite) errorRecorder(err error) {
+	suite.printRecorder("%s", err.Error())
+}
+
+func (suite *PackageTestSuite) loadFile(filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return data
+}
+
+func (suite *PackageTestSuite) exampleHandler(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		suite.T().Fatalf("%s", err)
+	}
+	suite.requestPath = r.URL.Path
+	suite.requestBody = requestBody
+	suite.acceptHeader = r.Header.Get("Accept")
+
+	w.Header().Set("Content-Type", suite.contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(suite.responseBody)
+}
+
+func (suite *PackageTestSuite) SetupSuite() {
+	config.ModuleName = "hello-world"
+
+	// reassign printing functions to allow us to check output
+	client.PrintMessage = suite.printRecorder
+	client.PrintMessageAndExit = suite.printRecorder
+	client.PrintErrorAndExit = suite.errorRecorder
+}
+
+func (suite *PackageTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(suite.exampleHandler))
+	config.DcosURL = suite.server.URL
+	config.ServiceName = "dev/hello-world"
+}
+
+func (suite *PackageTestSuite) TearDownTest() {
+	suite.capturedOutput.Reset()
+	suite.server.Close()
+	config.ServiceName = "hello-world"
+}
+
+func TestPackageTestSuite(t *testing.T) {
+	suite.Run(t, new(PackageTestSuite))
+}
+
+func (suite *PackageTestSuite) TestInstall() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/package/install.json")
+	suite.contentType = "application/vnd.dcos.package.install-response+json;charset=utf-8;version=v2"
+
+	assert.True(suite.T(), doInstall("hello-world", "testdata/input/install-options.json", "2.0.0-0.1.0"))
+
+	assert.Equal(suite.T(), "/cosmos/package/install", suite.requestPath)
+	assert.Equal(suite.T(),
+		"application/vnd.dcos.package.install-response+json;charset=utf-8;version=v2,"+
+			"application/vnd.dcos.package.install-response+json;charset=utf-8;version=v1;q=0.9",
+		suite.acceptHeader)
+	assert.JSONEq(suite.T(), string(suite.loadFile("testdata/requests/install.json")), string(suite.requestBody))
+	assert.Equal(suite.T(), "Installing hello-world 2.0.0-0.1.0 as service /dev/hello-world.\nDC/OS hello-world is being installed!\n",
+		suite.capturedOutput.String())
+}
+
+func (suite *PackageTestSuite) TestInstallUnsupportedVersion() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/package/install.json")
+	suite.contentType = "application/vnd.dcos.package.install-response+json;charset=utf-8;version=v3"
+
+	assert.False(suite.T(), doInstall("hello-world", "", ""))
+	assert.Equal(suite.T(), "Cosmos responded to package install with unsupported response version v3 (supported: v2, v1)\n",
+		suite.capturedOutput.String())
+}
+
+func (suite *PackageTestSuite) TestInstallOptionsNameMismatch() {
+	config.ServiceName = "other"
+	_, err := installOptions("testdata/input/config.json")
+	assert.EqualError(suite.T(), err, "service.name 'hello-world' in options doesn't match the service name 'other'. Specify the name with '--name=<name>'.")
+}
+
+func (suite *PackageTestSuite) TestUninstall() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/package/uninstall.json")
+	suite.contentType = "application/vnd.dcos.package.uninstall-response+json;charset=utf-8;version=v1"
+
+	assert.True(suite.T(), doUninstall("hello-world", true))
+
+	assert.Equal(suite.T(), "/cosmos/package/uninstall", suite.requestPath)
+	assert.JSONEq(suite.T(), `{"packageName":"hello-world","appId":"dev/hello-world"}`, string(suite.requestBody))
+	assert.Equal(suite.T(), "Uninstalling hello-world 2.0.0-0.1.0 service /dev/hello-world.\nDC/OS hello-world is being uninstalled.\n",
+		suite.capturedOutput.String())
+}
+
+func (suite *PackageTestSuite) TestInstancesTable() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/package/list.json")
+	suite.contentType = "application/vnd.dcos.package.list-response+json;charset=utf-8;version=v1"
+
+	responseBytes, version, err := client.HTTPCosmosPackagePostJSON("list", `{"packageName":"hello-world"}`)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "v1", version)
+	assert.Equal(suite.T(), "/cosmos/package/list", suite.requestPath)
+
+	var response listResponse
+	assert.NoError(suite.T(), json.Unmarshal(responseBytes, &response))
+	expectedOutput := `NAME             PACKAGE      VERSION
+dev/hello-world  hello-world  1.9.0-0.1.0
+hello-world      hello-world  2.0.0-0.1.0`
+	assert.Equal(suite.T(), expectedOutput, toInstancesTable(response.Packages))
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"net/http"
 
@@ -21,6 +23,10 @@ type planHandler struct {
 	Phase      string
 	Step       string
 	RawJSON    bool
+	Status     string
+	StepRegex  string
+	All        bool
+	Yes        bool
 }
 
 func getVariablePair(pairString string) ([]string, error) {
@@ -83,7 +89,7 @@ func checkPlansResponse(response *http.Response, body []byte) error {
 	case response.StatusCode == http.StatusNotFound:
 		if string(body) == "Element not found" {
 			// The scheduler itself is returning the 404 (otherwise we fall through to the default Adminrouter case)
-			return errors.New("Plan, phase and/or step does not exist.")
+			return createPlanElementNotFoundError(response.Request)
 		}
 	case response.StatusCode == http.StatusAlreadyReported:
 		return errors.New("Cannot execute command. Command has already been issued or the plan has completed.")
@@ -91,6 +97,26 @@ func checkPlansResponse(response *http.Response, body []byte) error {
 	return nil
 }
 
+// createPlanElementNotFoundError returns an error identifying the plan, phase and step which were
+// requested, e.g. from "v1/plans/deploy/restart?phase=hello".
+func createPlanElementNotFoundError(request *http.Request) error {
+	notFound := &client.PlanElementNotFoundError{}
+	if request == nil {
+		return notFound
+	}
+	segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "plans" && i+1 < len(segments) {
+			notFound.Plan = segments[i+1]
+			break
+		}
+	}
+	query := request.URL.Query()
+	notFound.Phase = query.Get("phase")
+	notFound.Step = query.Get("step")
+	return notFound
+}
+
 func getQueryWithPhaseAndStep(phase, step string) url.Values {
 	query := url.Values{}
 	if len(phase) > 0 {
@@ -107,7 +133,7 @@ func forceComplete(planName, phase, step string) {
 	client.SetCustomResponseCheck(checkPlansResponse)
 	responseBytes, err := client.HTTPServicePostQuery(fmt.Sprintf("v1/plans/%s/forceComplete", planName), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	if parseJSONResponse(responseBytes) {
 		client.PrintMessage("\"%s\" plan: step \"%s\" in phase \"%s\" has been forced to complete.", planName, step, phase)
@@ -118,6 +144,22 @@ func forceComplete(planName, phase, step string) {
 
 func (cmd *planHandler) handleForceComplete(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
+	if cmd.hasStepSelectors() {
+		if len(cmd.Step) > 0 {
+			client.PrintMessageAndExit("A step cannot be provided along with --status, --step-regex or --all.")
+			return nil
+		}
+		cmd.bulkApply("forceComplete", "forced to complete")
+		return nil
+	}
+	if len(cmd.Phase) == 0 || len(cmd.Step) == 0 {
+		client.PrintMessageAndExit("A phase and step must be provided, or select steps with --status, --step-regex or --all.")
+		return nil
+	}
+	if !client.Confirm(fmt.Sprintf("Force step \"%s\" in phase \"%s\" of \"%s\" plan to complete without running it?", cmd.Step, cmd.Phase, cmd.getPlanName()), cmd.Yes) {
+		client.PrintMessageAndExit("Cancelled.")
+		return nil
+	}
 	forceComplete(cmd.getPlanName(), cmd.Phase, cmd.Step)
 	return nil
 }
@@ -127,7 +169,7 @@ func restart(planName, phase, step string) {
 	client.SetCustomResponseCheck(checkPlansResponse)
 	responseBytes, err := client.HTTPServicePostQuery(fmt.Sprintf("v1/plans/%s/restart", planName), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	if parseJSONResponse(responseBytes) {
 		if step == "" && phase == "" {
@@ -150,15 +192,130 @@ func restart(planName, phase, step string) {
 
 func (cmd *planHandler) handleForceRestart(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
+	if cmd.hasStepSelectors() {
+		if len(cmd.Step) > 0 {
+			client.PrintMessageAndExit("A step cannot be provided along with --status, --step-regex or --all.")
+			return nil
+		}
+		cmd.bulkApply("restart", "restarted")
+		return nil
+	}
 	restart(cmd.getPlanName(), cmd.Phase, cmd.Step)
 	return nil
 }
 
+// selectedStep is a step which was matched by a bulk operation's selectors, along with its phase.
+type selectedStep struct {
+	Phase phaseInfo
+	Step  stepInfo
+}
+
+// hasStepSelectors returns whether any of the bulk operation selectors were provided.
+func (cmd *planHandler) hasStepSelectors() bool {
+	return len(cmd.Status) > 0 || len(cmd.StepRegex) > 0 || cmd.All
+}
+
+// selectSteps returns the steps of the plan which match all of the provided selectors, in plan
+// order. The phase (name or UUID) is optional, except when selecting all steps.
+func selectSteps(plan *planInfo, phase, status, stepRegex string, all bool) ([]selectedStep, error) {
+	if all && len(phase) == 0 {
+		return nil, errors.New("--all requires a phase.")
+	}
+	var pattern *regexp.Regexp
+	if len(stepRegex) > 0 {
+		var err error
+		pattern, err = regexp.Compile("^(?:" + stepRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --step-regex '%s': %s", stepRegex, err)
+		}
+	}
+	foundPhase := len(phase) == 0
+	selected := make([]selectedStep, 0)
+	for _, phaseInfo := range plan.Phases {
+		if len(phase) > 0 && phase != phaseInfo.Name && phase != phaseInfo.ID {
+			continue
+		}
+		foundPhase = true
+		for _, step := range phaseInfo.Steps {
+			if len(status) > 0 && !strings.EqualFold(status, step.Status) {
+				continue
+			}
+			if pattern != nil && !pattern.MatchString(step.Name) {
+				continue
+			}
+			selected = append(selected, selectedStep{Phase: phaseInfo, Step: step})
+		}
+	}
+	if !foundPhase {
+		return nil, fmt.Errorf("Phase '%s' does not exist.", phase)
+	}
+	return selected, nil
+}
+
+// applyToStep sends a step command (e.g. "restart" or "forceComplete") to the scheduler.
+func applyToStep(planName, command, phase, step string) error {
+	query := getQueryWithPhaseAndStep(phase, step)
+	client.SetCustomResponseCheck(checkPlansResponse)
+	responseBytes, err := client.HTTPServicePostQuery(fmt.Sprintf("v1/plans/%s/%s", planName, command), query.Encode())
+	if err != nil {
+		return err
+	}
+	if !parseJSONResponse(responseBytes) {
+		return errors.New("Unexpected response from scheduler")
+	}
+	return nil
+}
+
+// bulkApply resolves the steps matching the handler's selectors, confirms them with the user, and
+// then applies the command to each in turn, printing a summary of the results.
+func (cmd *planHandler) bulkApply(command, description string) {
+	planName := cmd.getPlanName()
+	plan, _, err := getPlan(planName)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return
+	}
+	steps, err := selectSteps(plan, cmd.Phase, cmd.Status, cmd.StepRegex, cmd.All)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return
+	}
+	if len(steps) == 0 {
+		client.PrintMessage("No steps in \"%s\" plan match the provided selectors.", planName)
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "The following %d step(s) in \"%s\" plan will be %s:", len(steps), planName, description)
+	for _, selected := range steps {
+		fmt.Fprintf(&buf, "\n  %s: %s (%s)", selected.Phase.Name, selected.Step.Name, selected.Step.Status)
+	}
+	client.PrintMessage(buf.String())
+	if !client.Confirm(fmt.Sprintf("Continue with %d step(s)?", len(steps)), cmd.Yes) {
+		client.PrintMessageAndExit("Cancelled.")
+		return
+	}
+
+	failures := make([]string, 0)
+	for _, selected := range steps {
+		// use IDs: step names aren't necessarily unique across phases
+		if err := applyToStep(planName, command, selected.Phase.ID, selected.Step.ID); err != nil {
+			failures = append(failures, fmt.Sprintf("  %s: %s: %s", selected.Phase.Name, selected.Step.Name, err))
+		}
+	}
+	summary := fmt.Sprintf("\"%s\" plan: %d/%d step(s) %s.", planName, len(steps)-len(failures), len(steps), description)
+	if len(failures) > 0 {
+		client.PrintMessageAndExit(fmt.Sprintf("%s Failed steps:\n%s", summary, strings.Join(failures, "\n")))
+		return
+	}
+	client.PrintMessage(summary)
+}
+
 func (cmd *planHandler) handleList(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
 	responseBytes, err := client.HTTPServiceGet("v1/plans")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(responseBytes)
 	return nil
@@ -183,7 +340,7 @@ func (cmd *planHandler) handlePause(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
 	err := pause(cmd.getPlanName(), cmd.Phase)
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	return nil
 }
@@ -207,7 +364,7 @@ func (cmd *planHandler) handleResume(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
 	err := resume(cmd.getPlanName(), cmd.Phase)
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	return nil
 }
@@ -225,7 +382,7 @@ func (cmd *planHandler) handleStart(c *kingpin.ParseContext) error {
 	client.SetCustomResponseCheck(checkPlansResponse)
 	responseBytes, err := client.HTTPServicePostData(fmt.Sprintf("v1/plans/%s/start", cmd.PlanName), payload, "application/json")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(responseBytes)
 	return nil
@@ -235,12 +392,17 @@ func printStatus(planName string, rawJSON bool) {
 	client.SetCustomResponseCheck(checkPlansResponse)
 	responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/plans/%s", planName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	if rawJSON {
 		client.PrintJSONBytes(responseBytes)
-	} else {
-		client.PrintMessage(toStatusTree(planName, responseBytes))
+		return
+	}
+	client.PrintMessage(toStatusTree(planName, responseBytes))
+	if plan, err := parsePlan(responseBytes); err == nil {
+		if eta := planETA(recordPlanObservation(planName, plan), plan, time.Now()); len(eta) > 0 {
+			client.PrintMessage("Progress: %s%s", planProgressSummary(planName, plan), eta)
+		}
 	}
 }
 
@@ -252,15 +414,115 @@ func (cmd *planHandler) handleStatus(c *kingpin.ParseContext) error {
 
 func (cmd *planHandler) handleStop(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
+	if !client.Confirm(fmt.Sprintf("Stop \"%s\" plan? All of its progress will be reset.", cmd.PlanName), cmd.Yes) {
+		client.PrintMessageAndExit("Cancelled.")
+		return nil
+	}
 	client.SetCustomResponseCheck(checkPlansResponse)
 	responseBytes, err := client.HTTPServicePost(fmt.Sprintf("v1/plans/%s/stop", cmd.PlanName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(responseBytes)
 	return nil
 }
 
+// Plan/phase/step statuses reported by the scheduler.
+const (
+	statusComplete = "COMPLETE"
+	statusError    = "ERROR"
+	statusPending  = "PENDING"
+	statusWaiting  = "WAITING"
+)
+
+type planInfo struct {
+	Phases   []phaseInfo `json:"phases"`
+	Errors   []string    `json:"errors"`
+	Strategy string      `json:"strategy"`
+	Status   string      `json:"status"`
+}
+
+type phaseInfo struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Steps    []stepInfo `json:"steps"`
+	Strategy string     `json:"strategy"`
+	Status   string     `json:"status"`
+}
+
+type stepInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func parsePlan(planJSONBytes []byte) (*planInfo, error) {
+	var plan planInfo
+	err := json.Unmarshal(planJSONBytes, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse JSON in plan response: %s", err)
+	}
+	return &plan, nil
+}
+
+// getPlan returns the current state of the named plan, along with the raw JSON it was parsed from.
+func getPlan(planName string) (*planInfo, []byte, error) {
+	client.SetCustomResponseCheck(checkPlansResponse)
+	responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/plans/%s", planName))
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := parsePlan(responseBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, responseBytes, nil
+}
+
+// getPlanNames returns the names of all plans offered by the scheduler.
+func getPlanNames() ([]string, error) {
+	responseBytes, err := client.HTTPServiceGet("v1/plans")
+	if err != nil {
+		return nil, err
+	}
+	return client.JSONBytesToArray(responseBytes)
+}
+
+// planProgressSummary returns a one-line summary of a plan's progress, e.g.:
+// deploy (IN_PROGRESS): 1/3 steps complete, active: kafka-1:[broker] (STARTING)
+func planProgressSummary(planName string, plan *planInfo) string {
+	completeSteps := 0
+	totalSteps := 0
+	activeSteps := make([]string, 0)
+	for _, phase := range plan.Phases {
+		for _, step := range phase.Steps {
+			totalSteps++
+			switch step.Status {
+			case statusComplete:
+				completeSteps++
+			case statusPending, statusWaiting:
+				// not active
+			default:
+				activeSteps = append(activeSteps, fmt.Sprintf("%s (%s)", step.Name, step.Status))
+			}
+		}
+	}
+	summary := fmt.Sprintf("%s (%s): %d/%d steps complete", planName, plan.Status, completeSteps, totalSteps)
+	if len(activeSteps) > 0 {
+		summary += ", active: " + strings.Join(activeSteps, ", ")
+	}
+	return summary
+}
+
+// addStepSelectorFlags adds the flags for selecting multiple steps to a force-complete or force-restart command.
+func (cmd *planHandler) addStepSelectorFlags(command *kingpin.CmdClause) {
+	command.Flag("status", "Select all steps with this status, e.g. ERROR").StringVar(&cmd.Status)
+	command.Flag("step-regex", "Select all steps whose names fully match this regular expression").StringVar(&cmd.StepRegex)
+	command.Flag("all", "Select all steps in the provided phase").BoolVar(&cmd.All)
+	command.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
+}
+
 // HandlePlanSection adds plan subcommands to the passed in kingpin.Application.
 func HandlePlanSection(app *kingpin.Application) {
 	// plan <active, continue, force, interrupt, restart, status/show>
@@ -269,13 +531,24 @@ func HandlePlanSection(app *kingpin.Application) {
 
 	forceComplete := plan.Command("force-complete", "Force complete a specific step in the provided phase").Alias("force").Action(cmd.handleForceComplete)
 	forceComplete.Arg("plan", "Name of the plan to force complete").Required().StringVar(&cmd.PlanName)
-	forceComplete.Arg("phase", "Name or UUID of the phase containing the provided step").Required().StringVar(&cmd.Phase)
-	forceComplete.Arg("step", "Name or UUID of step to be restarted").Required().StringVar(&cmd.Step)
+	forceComplete.Arg("phase", "Name or UUID of the phase containing the provided step").StringVar(&cmd.Phase)
+	forceComplete.Arg("step", "Name or UUID of step to be restarted").StringVar(&cmd.Step)
+	cmd.addStepSelectorFlags(forceComplete)
 
 	forceRestart := plan.Command("force-restart", "Restart a deploy plan, or specific step in the provided phase").Alias("restart").Action(cmd.handleForceRestart)
 	forceRestart.Arg("plan", "Name of the plan to restart").Required().StringVar(&cmd.PlanName)
 	forceRestart.Arg("phase", "Name or UUID of the phase containing the provided step").StringVar(&cmd.Phase) // TODO optional
 	forceRestart.Arg("step", "Name or UUID of step to be restarted").StringVar(&cmd.Step)
+	cmd.addStepSelectorFlags(forceRestart)
+
+	exportCmd := &planExportHandler{}
+	export := plan.Command("export", "Export the plan with the provided name as a diagram, with status colors and strategy annotations").Action(exportCmd.handleExport)
+	export.Arg("plan", "Name of the plan to export").Required().StringVar(&exportCmd.PlanName)
+	export.Flag("format", "Diagram format: dot (Graphviz), mermaid or svg").Default(exportFormatDot).EnumVar(&exportCmd.Format, exportFormatDot, exportFormatMermaid, exportFormatSVG)
+
+	historyCmd := &planHistoryHandler{}
+	history := plan.Command("history", "Show the locally recorded status transitions and step durations of the plan with the provided name").Action(historyCmd.handleHistory)
+	history.Arg("plan", "Name of the plan to show").Default("deploy").StringVar(&historyCmd.PlanName)
 
 	plan.Command("list", "Show all plans for this service").Action(cmd.handleList)
 
@@ -297,6 +570,7 @@ func HandlePlanSection(app *kingpin.Application) {
 
 	stop := plan.Command("stop", "Stop the plan with the provided name").Action(cmd.handleStop)
 	stop.Arg("plan", "Name of the plan to stop").Required().StringVar(&cmd.PlanName)
+	stop.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
 }
 
 func toStatusTree(planName string, planJSONBytes []byte) string {
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Supported plan export formats.
+const (
+	exportFormatDot     = "dot"
+	exportFormatMermaid = "mermaid"
+	exportFormatSVG     = "svg"
+)
+
+// statusColor returns the fill color used for plan elements with the provided status.
+func statusColor(status string) string {
+	switch status {
+	case statusComplete:
+		return "#a3d9a5"
+	case statusError:
+		return "#f4a6a6"
+	case statusPending:
+		return "#e0e0e0"
+	case statusWaiting:
+		return "#f9e79f"
+	default:
+		// PREPARED, STARTING, IN_PROGRESS, ...
+		return "#a9cce3"
+	}
+}
+
+// elementLabel returns a label for a plan or phase, e.g. "deploy (IN_PROGRESS, serial)".
+func elementLabel(name, status, strategy string) string {
+	if len(strategy) == 0 {
+		return fmt.Sprintf("%s (%s)", name, status)
+	}
+	return fmt.Sprintf("%s (%s, %s)", name, status, strategy)
+}
+
+// isSerial returns whether a strategy runs its children one at a time. Custom strategies are
+// treated as serial.
+func isSerial(strategy string) bool {
+	return strategy != "parallel"
+}
+
+func dotEscape(value string) string {
+	return strings.Replace(strings.Replace(value, `\`, `\\`, -1), `"`, `\"`, -1)
+}
+
+// toPlanDot renders the plan as a Graphviz digraph, with a cluster per phase.
+func toPlanDot(planName string, plan *planInfo) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph \"%s\" {\n", dotEscape(planName))
+	buf.WriteString("  compound=true;\n  rankdir=LR;\n  labelloc=t;\n")
+	fmt.Fprintf(&buf, "  label=\"%s\";\n", dotEscape(elementLabel(planName, plan.Status, plan.Strategy)))
+	buf.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	for i, phase := range plan.Phases {
+		fmt.Fprintf(&buf, "  subgraph \"cluster_%d\" {\n", i)
+		fmt.Fprintf(&buf, "    label=\"%s\";\n", dotEscape(elementLabel(phase.Name, phase.Status, phase.Strategy)))
+		fmt.Fprintf(&buf, "    style=filled;\n    fillcolor=\"%s\";\n", statusColor(phase.Status))
+		for j, step := range phase.Steps {
+			fmt.Fprintf(&buf, "    \"step_%d_%d\" [label=\"%s\\n%s\", fillcolor=\"%s\"];\n",
+				i, j, dotEscape(step.Name), step.Status, statusColor(step.Status))
+		}
+		if isSerial(phase.Strategy) {
+			for j := 1; j < len(phase.Steps); j++ {
+				fmt.Fprintf(&buf, "    \"step_%d_%d\" -> \"step_%d_%d\";\n", i, j-1, i, j)
+			}
+		}
+		buf.WriteString("  }\n")
+	}
+	if isSerial(plan.Strategy) {
+		for i := 1; i < len(plan.Phases); i++ {
+			previous := len(plan.Phases[i-1].Steps) - 1
+			if previous < 0 || len(plan.Phases[i].Steps) == 0 {
+				continue
+			}
+			fmt.Fprintf(&buf, "  \"step_%d_%d\" -> \"step_%d_0\" [ltail=\"cluster_%d\", lhead=\"cluster_%d\"];\n",
+				i-1, previous, i, i-1, i)
+		}
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+func mermaidEscape(value string) string {
+	return strings.Replace(value, `"`, "#quot;", -1)
+}
+
+// mermaidClass returns the class name used for elements with the provided status, e.g. "in_progress".
+func mermaidClass(status string) string {
+	return strings.ToLower(status)
+}
+
+// toPlanMermaid renders the plan as a Mermaid flowchart, with a subgraph per phase.
+func toPlanMermaid(planName string, plan *planInfo) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "---\ntitle: \"%s\"\n---\n", mermaidEscape(elementLabel(planName, plan.Status, plan.Strategy)))
+	buf.WriteString("flowchart LR\n")
+	classes := make(map[string][]string)
+	classOrder := make([]string, 0)
+	addClass := func(status, id string) {
+		class := mermaidClass(status)
+		if _, ok := classes[class]; !ok {
+			classOrder = append(classOrder, status)
+		}
+		classes[class] = append(classes[class], id)
+	}
+	for i, phase := range plan.Phases {
+		phaseID := fmt.Sprintf("phase_%d", i)
+		fmt.Fprintf(&buf, "  subgraph %s[\"%s\"]\n", phaseID, mermaidEscape(elementLabel(phase.Name, phase.Status, phase.Strategy)))
+		for j, step := range phase.Steps {
+			stepID := fmt.Sprintf("step_%d_%d", i, j)
+			fmt.Fprintf(&buf, "    %s[\"%s<br/>%s\"]\n", stepID, mermaidEscape(step.Name), step.Status)
+			addClass(step.Status, stepID)
+		}
+		if isSerial(phase.Strategy) {
+			for j := 1; j < len(phase.Steps); j++ {
+				fmt.Fprintf(&buf, "    step_%d_%d --> step_%d_%d\n", i, j-1, i, j)
+			}
+		}
+		buf.WriteString("  end\n")
+		addClass(phase.Status, phaseID)
+	}
+	if isSerial(plan.Strategy) {
+		for i := 1; i < len(plan.Phases); i++ {
+			fmt.Fprintf(&buf, "  phase_%d --> phase_%d\n", i-1, i)
+		}
+	}
+	for _, status := range classOrder {
+		class := mermaidClass(status)
+		fmt.Fprintf(&buf, "  classDef %s fill:%s\n", class, statusColor(status))
+		fmt.Fprintf(&buf, "  class %s %s\n", strings.Join(classes[class], ","), class)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// Dimensions of the SVG layout, where phases are columns of steps running left to right.
+const (
+	svgMargin       = 20
+	svgTitleHeight  = 30
+	svgPhaseWidth   = 240
+	svgPhaseGap     = 40
+	svgPhaseHeader  = 40
+	svgStepHeight   = 40
+	svgStepGap      = 12
+	svgStepInset    = 12
+	svgFontSize     = 12
+	svgArrowMarker  = "arrow"
+	svgStrokeColor  = "#555555"
+	svgPhaseOpacity = "0.35"
+)
+
+// toPlanSVG renders the plan as a standalone SVG image, without requiring Graphviz.
+func toPlanSVG(planName string, plan *planInfo) string {
+	maxSteps := 0
+	for _, phase := range plan.Phases {
+		if len(phase.Steps) > maxSteps {
+			maxSteps = len(phase.Steps)
+		}
+	}
+	phaseHeight := svgPhaseHeader + maxSteps*(svgStepHeight+svgStepGap)
+	width := 2*svgMargin + len(plan.Phases)*svgPhaseWidth + (len(plan.Phases)-1)*svgPhaseGap
+	if len(plan.Phases) == 0 {
+		width = 2*svgMargin + svgPhaseWidth
+	}
+	height := 2*svgMargin + svgTitleHeight + phaseHeight
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"Helvetica, Arial, sans-serif\" font-size=\"%d\">\n",
+		width, height, svgFontSize)
+	fmt.Fprintf(&buf, "  <defs><marker id=\"%s\" markerWidth=\"10\" markerHeight=\"10\" refX=\"9\" refY=\"5\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"%s\"/></marker></defs>\n",
+		svgArrowMarker, svgStrokeColor)
+	fmt.Fprintf(&buf, "  <text x=\"%d\" y=\"%d\" font-size=\"%d\" font-weight=\"bold\">%s</text>\n",
+		svgMargin, svgMargin+svgFontSize+4, svgFontSize+4, html.EscapeString(elementLabel(planName, plan.Status, plan.Strategy)))
+
+	top := svgMargin + svgTitleHeight
+	for i, phase := range plan.Phases {
+		left := svgMargin + i*(svgPhaseWidth+svgPhaseGap)
+		fmt.Fprintf(&buf, "  <g class=\"phase\">\n")
+		fmt.Fprintf(&buf, "    <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"6\" fill=\"%s\" fill-opacity=\"%s\" stroke=\"%s\"/>\n",
+			left, top, svgPhaseWidth, phaseHeight, statusColor(phase.Status), svgPhaseOpacity, svgStrokeColor)
+		fmt.Fprintf(&buf, "    <text x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n",
+			left+svgStepInset, top+svgPhaseHeader/2+svgFontSize/2, html.EscapeString(elementLabel(phase.Name, phase.Status, phase.Strategy)))
+		for j, step := range phase.Steps {
+			stepTop := top + svgPhaseHeader + j*(svgStepHeight+svgStepGap)
+			fmt.Fprintf(&buf, "    <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"4\" fill=\"%s\" stroke=\"%s\"/>\n",
+				left+svgStepInset, stepTop, svgPhaseWidth-2*svgStepInset, svgStepHeight, statusColor(step.Status), svgStrokeColor)
+			fmt.Fprintf(&buf, "    <text x=\"%d\" y=\"%d\">%s</text>\n",
+				left+2*svgStepInset, stepTop+svgStepHeight/2-2, html.EscapeString(step.Name))
+			fmt.Fprintf(&buf, "    <text x=\"%d\" y=\"%d\" font-size=\"%d\">%s</text>\n",
+				left+2*svgStepInset, stepTop+svgStepHeight/2+svgFontSize, svgFontSize-2, step.Status)
+			if j > 0 && isSerial(phase.Strategy) {
+				x := left + svgPhaseWidth/2
+				fmt.Fprintf(&buf, "    <line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"%s\" marker-end=\"url(#%s)\"/>\n",
+					x, stepTop-svgStepGap, x, stepTop, svgStrokeColor, svgArrowMarker)
+			}
+		}
+		buf.WriteString("  </g>\n")
+		if i > 0 && isSerial(plan.Strategy) {
+			y := top + phaseHeight/2
+			fmt.Fprintf(&buf, "  <line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"%s\" marker-end=\"url(#%s)\"/>\n",
+				left-svgPhaseGap, y, left, y, svgStrokeColor, svgArrowMarker)
+		}
+	}
+	buf.WriteString("</svg>")
+	return buf.String()
+}
+
+// exportPlan renders the plan in the requested format.
+func exportPlan(planName string, plan *planInfo, format string) (string, error) {
+	switch format {
+	case exportFormatDot:
+		return toPlanDot(planName, plan), nil
+	case exportFormatMermaid:
+		return toPlanMermaid(planName, plan), nil
+	case exportFormatSVG:
+		return toPlanSVG(planName, plan), nil
+	default:
+		return "", fmt.Errorf("Unsupported export format '%s'", format)
+	}
+}
+
+type planExportHandler struct {
+	PlanName string
+	Format   string
+}
+
+func (cmd *planExportHandler) handleExport(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	plan, _, err := getPlan(cmd.PlanName)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return nil
+	}
+	output, err := exportPlan(cmd.PlanName, plan, cmd.Format)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+		return nil
+	}
+	client.PrintMessage("%s", output)
+	return nil
+}
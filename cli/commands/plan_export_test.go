@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadExportPlan(t *testing.T) *planInfo {
+	data, err := ioutil.ReadFile("testdata/responses/scheduler/plan-status-backup.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := parsePlan(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func assertExport(t *testing.T, format, expectedFile string) {
+	output, err := exportPlan("backup-s3", loadExportPlan(t), format)
+	assert.NoError(t, err)
+	expectedOutput, err := ioutil.ReadFile(expectedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.TrimRight(string(expectedOutput), "\n"), output)
+}
+
+func TestExportPlanDot(t *testing.T) {
+	assertExport(t, exportFormatDot, "testdata/output/plan-export.dot")
+}
+
+func TestExportPlanMermaid(t *testing.T) {
+	assertExport(t, exportFormatMermaid, "testdata/output/plan-export.mmd")
+}
+
+func TestExportPlanSVG(t *testing.T) {
+	assertExport(t, exportFormatSVG, "testdata/output/plan-export.svg")
+}
+
+func TestExportPlanUnsupportedFormat(t *testing.T) {
+	_, err := exportPlan("backup-s3", loadExportPlan(t), "png")
+	assert.EqualError(t, err, "Unsupported export format 'png'")
+}
+
+func TestExportEscaping(t *testing.T) {
+	assert.Equal(t, `say \"hi\" \\ bye`, dotEscape(`say "hi" \ bye`))
+	assert.Equal(t, `say #quot;hi#quot;`, mermaidEscape(`say "hi"`))
+}
@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// maxStepTransitions limits how many status transitions are retained for each step.
+const maxStepTransitions = 100
+
+type statusTransition struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// stepHistory is the observed status transitions of a step. Steps are identified by phase and step
+// name, which unlike their UUIDs are retained across scheduler restarts and updates.
+type stepHistory struct {
+	Phase       string             `json:"phase"`
+	Step        string             `json:"step"`
+	Transitions []statusTransition `json:"transitions"`
+}
+
+type planHistory struct {
+	Steps []*stepHistory `json:"steps"`
+}
+
+// localStatePath returns a file within the local state of the given kind for the current cluster and
+// service, or an empty string if local state is disabled.
+func localStatePath(kind, name string) string {
+	if len(config.StateDir) == 0 {
+		return ""
+	}
+	cluster := config.DcosURL
+	if parsedURL, err := url.Parse(config.DcosURL); err == nil && len(parsedURL.Host) > 0 {
+		cluster = parsedURL.Host
+	}
+	return filepath.Join(config.StateDir, kind, url.PathEscape(cluster),
+		url.PathEscape(strings.Trim(config.ServiceName, "/")), url.PathEscape(name)+".json")
+}
+
+// loadLocalState reads the file into state, leaving state unchanged if the file doesn't exist.
+func loadLocalState(path string, state interface{}) error {
+	stateBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return fmt.Errorf("Failed to parse %s: %s", path, err)
+	}
+	return nil
+}
+
+func saveLocalState(path string, state interface{}) error {
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so that concurrent readers never see a partial file:
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, stateBytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// planHistoryPath returns the file which holds the history of the plan for the current cluster and
+// service, or an empty string if local state is disabled.
+func planHistoryPath(planName string) string {
+	return localStatePath("plan-history", planName)
+}
+
+func loadPlanHistory(path string) (*planHistory, error) {
+	history := &planHistory{}
+	if err := loadLocalState(path, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (h *planHistory) step(phaseName, stepName string) *stepHistory {
+	for _, step := range h.Steps {
+		if step.Phase == phaseName && step.Step == stepName {
+			return step
+		}
+	}
+	return nil
+}
+
+// observe records any changes in the plan's step statuses, returning whether anything changed.
+func (h *planHistory) observe(plan *planInfo, now time.Time) bool {
+	changed := false
+	for _, phase := range plan.Phases {
+		for _, step := range phase.Steps {
+			history := h.step(phase.Name, step.Name)
+			if history == nil {
+				history = &stepHistory{Phase: phase.Name, Step: step.Name}
+				h.Steps = append(h.Steps, history)
+			}
+			count := len(history.Transitions)
+			if count > 0 && history.Transitions[count-1].Status == step.Status {
+				continue
+			}
+			history.Transitions = append(history.Transitions, statusTransition{Status: step.Status, Time: now})
+			if len(history.Transitions) > maxStepTransitions {
+				history.Transitions = history.Transitions[len(history.Transitions)-maxStepTransitions:]
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// recordPlanObservation adds the current state of the plan to its local history. Failures are only
+// reported in verbose mode, as the history is informational.
+func recordPlanObservation(planName string, plan *planInfo) *planHistory {
+	path := planHistoryPath(planName)
+	if len(path) == 0 {
+		return nil
+	}
+	history, err := loadPlanHistory(path)
+	if err == nil && history.observe(plan, time.Now()) {
+		err = saveLocalState(path, history)
+	}
+	if err != nil {
+		if config.Verbose {
+			client.PrintMessage("Failed to record history of %s plan: %s", planName, err)
+		}
+		return nil
+	}
+	return history
+}
+
+// durations returns how long each observed run of the step took to complete, from the first
+// observation of it having started through to the first observation of it being complete.
+func (s *stepHistory) durations() []time.Duration {
+	durations := make([]time.Duration, 0)
+	var startTime time.Time
+	for _, transition := range s.Transitions {
+		switch {
+		case transition.Status == statusPending:
+			startTime = time.Time{}
+		case transition.Status == statusComplete:
+			if !startTime.IsZero() {
+				durations = append(durations, transition.Time.Sub(startTime))
+			}
+			startTime = time.Time{}
+		case stepStarted(transition.Status) && startTime.IsZero():
+			startTime = transition.Time
+		}
+	}
+	return durations
+}
+
+// startedAt returns when the step's current run was first observed to have started, if it's in progress.
+func (s *stepHistory) startedAt() time.Time {
+	var startTime time.Time
+	for _, transition := range s.Transitions {
+		switch {
+		case transition.Status == statusPending || transition.Status == statusComplete:
+			startTime = time.Time{}
+		case stepStarted(transition.Status) && startTime.IsZero():
+			startTime = transition.Time
+		}
+	}
+	return startTime
+}
+
+func averageDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	return total / time.Duration(len(durations))
+}
+
+// expectedDuration returns the expected duration of a step, based on its own past runs, or else on
+// those of the other steps in its phase, or else those of all steps in the plan. Returns zero if no
+// runs have been observed.
+func (h *planHistory) expectedDuration(phaseName, stepName string) time.Duration {
+	if step := h.step(phaseName, stepName); step != nil {
+		if durations := step.durations(); len(durations) > 0 {
+			return averageDuration(durations)
+		}
+	}
+	phaseDurations := make([]time.Duration, 0)
+	planDurations := make([]time.Duration, 0)
+	for _, step := range h.Steps {
+		durations := step.durations()
+		if step.Phase == phaseName {
+			phaseDurations = append(phaseDurations, durations...)
+		}
+		planDurations = append(planDurations, durations...)
+	}
+	if len(phaseDurations) > 0 {
+		return averageDuration(phaseDurations)
+	}
+	return averageDuration(planDurations)
+}
+
+// estimateRemaining returns the estimated time until the plan completes, based on past step
+// durations. Steps in parallel phases (and phases in parallel plans) are assumed to overlap. Returns
+// false if there's no history to estimate from.
+func (h *planHistory) estimateRemaining(plan *planInfo, now time.Time) (time.Duration, bool) {
+	var planRemaining time.Duration
+	for _, phase := range plan.Phases {
+		var phaseRemaining time.Duration
+		for _, step := range phase.Steps {
+			if step.Status == statusComplete {
+				continue
+			}
+			expected := h.expectedDuration(phase.Name, step.Name)
+			if expected == 0 {
+				return 0, false
+			}
+			remaining := expected
+			if history := h.step(phase.Name, step.Name); history != nil && stepStarted(step.Status) {
+				if startTime := history.startedAt(); !startTime.IsZero() {
+					remaining -= now.Sub(startTime)
+				}
+			}
+			if remaining < 0 {
+				remaining = 0
+			}
+			if isSerial(phase.Strategy) {
+				phaseRemaining += remaining
+			} else if remaining > phaseRemaining {
+				phaseRemaining = remaining
+			}
+		}
+		if isSerial(plan.Strategy) {
+			planRemaining += phaseRemaining
+		} else if phaseRemaining > planRemaining {
+			planRemaining = phaseRemaining
+		}
+	}
+	return planRemaining, true
+}
+
+// planETA returns a suffix for progress messages with the estimated time remaining, if available.
+func planETA(history *planHistory, plan *planInfo, now time.Time) string {
+	if history == nil || plan.Status == statusComplete {
+		return ""
+	}
+	remaining, ok := history.estimateRemaining(plan, now)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(", estimated %s remaining", remaining.Round(time.Second))
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration == 0 {
+		return "-"
+	}
+	return duration.Round(time.Second).String()
+}
+
+// toPlanHistory returns a table of each step's current status, when it last changed, and its
+// durations, followed by the estimated time remaining if the plan is in progress.
+func toPlanHistory(planName string, plan *planInfo, history *planHistory, now time.Time) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "PHASE\tSTEP\tSTATUS\tSINCE\tLAST DURATION\tAVERAGE DURATION\tRUNS\n")
+	for _, step := range history.Steps {
+		if len(step.Transitions) == 0 {
+			continue
+		}
+		last := step.Transitions[len(step.Transitions)-1]
+		durations := step.durations()
+		var lastDuration time.Duration
+		if len(durations) > 0 {
+			lastDuration = durations[len(durations)-1]
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", step.Phase, step.Step, last.Status,
+			last.Time.UTC().Format(time.RFC3339), formatDuration(lastDuration), formatDuration(averageDuration(durations)), len(durations))
+	}
+	writer.Flush()
+	if plan != nil && plan.Status != statusComplete {
+		if remaining, ok := history.estimateRemaining(plan, now); ok {
+			fmt.Fprintf(&buf, "%s plan is %s: estimated %s remaining\n", planName, plan.Status, remaining.Round(time.Second))
+		} else {
+			fmt.Fprintf(&buf, "%s plan is %s: not enough history to estimate time remaining\n", planName, plan.Status)
+		}
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+type planHistoryHandler struct {
+	PlanName string
+}
+
+func (cmd *planHistoryHandler) handleHistory(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	if len(config.StateDir) == 0 {
+		client.PrintMessageAndExit("Plan history is unavailable: the local state directory could not be determined.")
+		return nil
+	}
+	// observe the current state of the plan, if the scheduler is reachable:
+	plan, _, err := getPlan(cmd.PlanName)
+	var history *planHistory
+	if err == nil {
+		history = recordPlanObservation(cmd.PlanName, plan)
+	} else {
+		client.PrintMessage("Unable to retrieve current state of %s plan: %s", cmd.PlanName, err)
+		plan = nil
+	}
+	if history == nil {
+		history, err = loadPlanHistory(planHistoryPath(cmd.PlanName))
+		if err != nil {
+			client.PrintErrorAndExit(err)
+			return nil
+		}
+	}
+	if len(history.Steps) == 0 {
+		client.PrintMessage("No history has been recorded for %s plan.", cmd.PlanName)
+		return nil
+	}
+	client.PrintMessage("%s", toPlanHistory(cmd.PlanName, plan, history, time.Now()))
+	return nil
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func historyTestPlan(strategy string, statuses ...string) *planInfo {
+	phase := phaseInfo{Name: "node", Strategy: strategy}
+	for i, status := range statuses {
+		phase.Steps = append(phase.Steps, stepInfo{Name: []string{"node-0:[server]", "node-1:[server]", "node-2:[server]"}[i], Status: status})
+	}
+	return &planInfo{Phases: []phaseInfo{phase}, Strategy: "serial", Status: "IN_PROGRESS"}
+}
+
+func observedHistory(start time.Time) *planHistory {
+	history := &planHistory{}
+	history.observe(historyTestPlan("serial", "PENDING", "PENDING", "PENDING"), start)
+	history.observe(historyTestPlan("serial", "STARTING", "PENDING", "PENDING"), start.Add(time.Minute))
+	history.observe(historyTestPlan("serial", "COMPLETE", "STARTING", "PENDING"), start.Add(3*time.Minute))
+	return history
+}
+
+func TestPlanHistoryObserve(t *testing.T) {
+	start := time.Date(2017, 7, 5, 18, 0, 0, 0, time.UTC)
+	history := observedHistory(start)
+	assert.False(t, history.observe(historyTestPlan("serial", "COMPLETE", "STARTING", "PENDING"), start.Add(4*time.Minute)))
+
+	assert.Equal(t, 3, len(history.Steps))
+	assert.Equal(t, []statusTransition{
+		{Status: "PENDING", Time: start},
+		{Status: "STARTING", Time: start.Add(time.Minute)},
+		{Status: "COMPLETE", Time: start.Add(3 * time.Minute)},
+	}, history.step("node", "node-0:[server]").Transitions)
+	assert.Equal(t, []time.Duration{2 * time.Minute}, history.step("node", "node-0:[server]").durations())
+	assert.Empty(t, history.step("node", "node-1:[server]").durations())
+	assert.Equal(t, start.Add(3*time.Minute), history.step("node", "node-1:[server]").startedAt())
+}
+
+func TestPlanHistoryEstimate(t *testing.T) {
+	start := time.Date(2017, 7, 5, 18, 0, 0, 0, time.UTC)
+	history := observedHistory(start)
+	now := start.Add(4 * time.Minute)
+
+	// node-1 is expected to take 2m and has been running for 1m, then node-2 is expected to take 2m:
+	remaining, ok := history.estimateRemaining(historyTestPlan("serial", "COMPLETE", "STARTING", "PENDING"), now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Minute, remaining)
+
+	// in a parallel phase, the remaining steps overlap:
+	remaining, ok = history.estimateRemaining(historyTestPlan("parallel", "COMPLETE", "STARTING", "PENDING"), now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, remaining)
+
+	_, ok = (&planHistory{}).estimateRemaining(historyTestPlan("serial", "PENDING"), now)
+	assert.False(t, ok)
+}
+
+func TestPlanHistoryTable(t *testing.T) {
+	start := time.Date(2017, 7, 5, 18, 0, 0, 0, time.UTC)
+	history := observedHistory(start)
+	plan := historyTestPlan("serial", "COMPLETE", "STARTING", "PENDING")
+
+	expectedOutput := `PHASE  STEP             STATUS    SINCE                 LAST DURATION  AVERAGE DURATION  RUNS
+node   node-0:[server]  COMPLETE  2017-07-05T18:03:00Z  2m0s           2m0s              1
+node   node-1:[server]  STARTING  2017-07-05T18:03:00Z  -              -                 0
+node   node-2:[server]  PENDING   2017-07-05T18:00:00Z  -              -                 0
+deploy plan is IN_PROGRESS: estimated 3m0s remaining`
+	assert.Equal(t, expectedOutput, toPlanHistory("deploy", plan, history, start.Add(4*time.Minute)))
+}
+
+func TestRecordPlanObservation(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "plan-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stateDir)
+	dcosURL, serviceName := config.DcosURL, config.ServiceName
+	defer func() {
+		config.StateDir, config.DcosURL, config.ServiceName = "", dcosURL, serviceName
+	}()
+	config.StateDir = stateDir
+	config.DcosURL = "https://cluster.example.com"
+	config.ServiceName = "/dev/kafka"
+
+	path := planHistoryPath("deploy")
+	assert.Equal(t, stateDir+"/plan-history/cluster.example.com/dev%2Fkafka/deploy.json", path)
+
+	recordPlanObservation("deploy", historyTestPlan("serial", "STARTING"))
+	recordPlanObservation("deploy", historyTestPlan("serial", "COMPLETE"))
+	history, err := loadPlanHistory(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(history.Steps))
+	assert.Equal(t, 2, len(history.step("node", "node-0:[server]").Transitions))
+
+	config.StateDir = ""
+	assert.Nil(t, recordPlanObservation("deploy", historyTestPlan("serial", "PENDING")))
+}
@@ -22,6 +22,8 @@ type PlanTestSuite struct {
 	requestBody    []byte
 	responseBody   []byte
 	responseStatus int
+	responses      map[string][]byte
+	requests       []string
 	capturedOutput bytes.Buffer
 }
 
@@ -30,6 +32,10 @@ func (suite *PlanTestSuite) printRecorder(format string, a ...interface{}) (n in
 	return 0, nil // this is probably sub-optimal in the general sense
 }
 
+func (suite *PlanTestSuite) errorRecorder(err error) {
+	suite.printRecorder("%s", err.Error())
+}
+
 func (suite *PlanTestSuite) loadFile(filename string) []byte {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -45,9 +51,14 @@ func (suite *PlanTestSuite) exampleHandler(w http.ResponseWriter, r *http.Reques
 		suite.T().Fatalf("%s", err)
 	}
 	suite.requestBody = requestBody
+	suite.requests = append(suite.requests, fmt.Sprintf("%s %s?%s", r.Method, r.URL.Path, r.URL.RawQuery))
 
 	w.WriteHeader(suite.responseStatus)
-	w.Write(suite.responseBody)
+	if responseBody, ok := suite.responses[r.URL.Path]; ok {
+		w.Write(responseBody)
+	} else {
+		w.Write(suite.responseBody)
+	}
 }
 
 func (suite *PlanTestSuite) SetupSuite() {
@@ -57,6 +68,7 @@ func (suite *PlanTestSuite) SetupSuite() {
 	// reassign printing functions to allow us to check output
 	client.PrintMessage = suite.printRecorder
 	client.PrintMessageAndExit = suite.printRecorder
+	client.PrintErrorAndExit = suite.errorRecorder
 }
 
 func (suite *PlanTestSuite) SetupTest() {
@@ -66,6 +78,8 @@ func (suite *PlanTestSuite) SetupTest() {
 }
 
 func (suite *PlanTestSuite) TearDownTest() {
+	suite.responses = nil
+	suite.requests = nil
 	suite.capturedOutput.Reset()
 	suite.server.Close()
 }
@@ -215,6 +229,9 @@ func (suite *PlanTestSuite) TestPauseBadPhase() {
 
 	expectedOutput := "Plan, phase and/or step does not exist."
 	assert.Equal(suite.T(), string(expectedOutput), err.Error())
+	if assert.IsType(suite.T(), &client.PlanElementNotFoundError{}, err) {
+		assert.Equal(suite.T(), "deploy", err.(*client.PlanElementNotFoundError).Plan)
+	}
 }
 
 func (suite *PlanTestSuite) TestPauseAlreadyPaused() {
@@ -516,3 +533,96 @@ func (suite *PlanTestSuite) TestPrintStatusTree() {
 	expectedOutput := suite.loadFile("testdata/output/deploy-tree-twophase.txt")
 	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
 }
+
+func (suite *PlanTestSuite) TestPlanProgressSummary() {
+	plan, err := parsePlan(suite.loadFile("testdata/responses/scheduler/plan-status.json"))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	expectedOutput := "deploy (IN_PROGRESS): 1/6 steps complete, active: kafka-1:[broker] (IN_PROGRESS)"
+	assert.Equal(suite.T(), expectedOutput, planProgressSummary("deploy", plan))
+}
+
+func (suite *PlanTestSuite) loadPlan(filename string) *planInfo {
+	plan, err := parsePlan(suite.loadFile(filename))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return plan
+}
+
+func selectedStepNames(steps []selectedStep) []string {
+	names := make([]string, 0, len(steps))
+	for _, selected := range steps {
+		names = append(names, selected.Phase.Name+"/"+selected.Step.Name)
+	}
+	return names
+}
+
+func (suite *PlanTestSuite) TestSelectSteps() {
+	plan := suite.loadPlan("testdata/responses/scheduler/plan-status-errors.json")
+
+	steps, err := selectSteps(plan, "", "error", "", false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"node-deploy/node-1:[server]", "node-deploy/node-3:[server]", "node-deploy/node-4:[server]", "node-init/node-0:[init]"}, selectedStepNames(steps))
+
+	steps, err = selectSteps(plan, "node-deploy", "", "node-[3-5]:.*", false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"node-deploy/node-3:[server]", "node-deploy/node-4:[server]"}, selectedStepNames(steps))
+
+	// regex must match the whole name:
+	steps, err = selectSteps(plan, "", "", "node-1", false)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), steps)
+
+	steps, err = selectSteps(plan, "5a1c4e2b-0f3d-4c8a-9b6e-2d7f1e3a4b02", "", "", true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"node-init/node-0:[init]", "node-init/node-1:[init]"}, selectedStepNames(steps))
+}
+
+func (suite *PlanTestSuite) TestSelectStepsErrors() {
+	plan := suite.loadPlan("testdata/responses/scheduler/plan-status-errors.json")
+
+	_, err := selectSteps(plan, "", "", "", true)
+	assert.EqualError(suite.T(), err, "--all requires a phase.")
+	_, err = selectSteps(plan, "node-missing", "ERROR", "", false)
+	assert.EqualError(suite.T(), err, "Phase 'node-missing' does not exist.")
+	_, err = selectSteps(plan, "", "", "node-[", false)
+	assert.Contains(suite.T(), err.Error(), "Invalid --step-regex 'node-[':")
+}
+
+func (suite *PlanTestSuite) TestBulkRestart() {
+	suite.responseBody = suite.loadFile("testdata/responses/scheduler/restart.json")
+	suite.responseStatus = http.StatusOK
+	suite.responses = map[string][]byte{
+		"/service/hello-world/v1/plans/deploy": suite.loadFile("testdata/responses/scheduler/plan-status-errors.json"),
+	}
+	cmd := planHandler{PlanName: "deploy", Phase: "node-deploy", Status: "ERROR", Yes: true}
+	cmd.bulkApply("restart", "restarted")
+
+	expectedOutput := `The following 3 step(s) in "deploy" plan will be restarted:
+  node-deploy: node-1:[server] (ERROR)
+  node-deploy: node-3:[server] (ERROR)
+  node-deploy: node-4:[server] (ERROR)
+"deploy" plan: 3/3 step(s) restarted.
+`
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+	assert.Equal(suite.T(), []string{
+		"GET /service/hello-world/v1/plans/deploy?",
+		"POST /service/hello-world/v1/plans/deploy/restart?phase=5a1c4e2b-0f3d-4c8a-9b6e-2d7f1e3a4b01&step=7b2d5f3c-1e4a-4d9b-8c7f-3e8a2f4b5c02",
+		"POST /service/hello-world/v1/plans/deploy/restart?phase=5a1c4e2b-0f3d-4c8a-9b6e-2d7f1e3a4b01&step=7b2d5f3c-1e4a-4d9b-8c7f-3e8a2f4b5c04",
+		"POST /service/hello-world/v1/plans/deploy/restart?phase=5a1c4e2b-0f3d-4c8a-9b6e-2d7f1e3a4b01&step=7b2d5f3c-1e4a-4d9b-8c7f-3e8a2f4b5c05",
+	}, suite.requests)
+}
+
+func (suite *PlanTestSuite) TestBulkForceCompleteNoMatches() {
+	suite.responseStatus = http.StatusOK
+	suite.responses = map[string][]byte{
+		"/service/hello-world/v1/plans/deploy": suite.loadFile("testdata/responses/scheduler/plan-status-errors.json"),
+	}
+	cmd := planHandler{PlanName: "deploy", StepRegex: "broker-.*", Yes: true}
+	cmd.bulkApply("forceComplete", "forced to complete")
+
+	assert.Equal(suite.T(), "No steps in \"deploy\" plan match the provided selectors.\n", suite.capturedOutput.String())
+	assert.Equal(suite.T(), 1, len(suite.requests))
+}
@@ -1,21 +1,82 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 
 	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 type podsHandler struct {
 	PodName string
+	Yes     bool
+}
+
+// podTaskInfo is the subset of the scheduler's v1/pods/<pod>/info response which describes the
+// impact of replacing the pod.
+type podTaskInfo struct {
+	Info struct {
+		Name    string `json:"name"`
+		SlaveID struct {
+			Value string `json:"value"`
+		} `json:"slaveId"`
+		Resources []podResource `json:"resources"`
+		Executor  struct {
+			Resources []podResource `json:"resources"`
+		} `json:"executor"`
+	} `json:"info"`
+	Status struct {
+		State string `json:"state"`
+	} `json:"status"`
+}
+
+type podResource struct {
+	Name   string `json:"name"`
+	Scalar struct {
+		Value float64 `json:"value"`
+	} `json:"scalar"`
+	Disk *struct {
+		Persistence *struct {
+			ID string `json:"id"`
+		} `json:"persistence"`
+		Volume struct {
+			ContainerPath string `json:"containerPath"`
+		} `json:"volume"`
+	} `json:"disk"`
+}
+
+// describePodReplacement describes the tasks and persistent volumes which would be destroyed by
+// replacing the pod, based on the scheduler's v1/pods/<pod>/info response.
+func describePodReplacement(podName string, infoBytes []byte) string {
+	var tasks []podTaskInfo
+	if err := json.Unmarshal(infoBytes, &tasks); err != nil {
+		return fmt.Sprintf("Replacing pod %s will destroy its tasks and permanently delete their persistent volumes. (Failed to parse pod info: %s)", podName, err)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Replacing pod %s will destroy the following tasks and permanently delete their persistent volumes:", podName)
+	for _, task := range tasks {
+		fmt.Fprintf(&buf, "\n  Task %s (%s) on agent %s", task.Info.Name, valueOrNone(task.Status.State), valueOrNone(task.Info.SlaveID.Value))
+		resources := append(append([]podResource{}, task.Info.Resources...), task.Info.Executor.Resources...)
+		for _, resource := range resources {
+			if resource.Disk == nil || resource.Disk.Persistence == nil {
+				continue
+			}
+			fmt.Fprintf(&buf, "\n    Volume %s: %.0f MB (persistence ID %s)",
+				resource.Disk.Volume.ContainerPath, resource.Scalar.Value, resource.Disk.Persistence.ID)
+		}
+	}
+	fmt.Fprintf(&buf, "\nThe pod will be relaunched on a new agent with empty volumes.")
+	return buf.String()
 }
 
 func (cmd *podsHandler) handleList(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/pods")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -28,7 +89,7 @@ func (cmd *podsHandler) handleStatus(c *kingpin.ParseContext) error {
 	}
 	body, err := client.HTTPServiceGet(endpointPath)
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -37,7 +98,7 @@ func (cmd *podsHandler) handleInfo(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet(fmt.Sprintf("v1/pods/%s/info", cmd.PodName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -46,7 +107,7 @@ func (cmd *podsHandler) handleRestart(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServicePost(fmt.Sprintf("v1/pods/%s/restart", cmd.PodName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintResponseText(body)
 
@@ -54,9 +115,22 @@ func (cmd *podsHandler) handleRestart(c *kingpin.ParseContext) error {
 }
 func (cmd *podsHandler) handleReplace(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
+	if !cmd.Yes && !config.NonInteractive {
+		var impact string
+		infoBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/pods/%s/info", cmd.PodName))
+		if err != nil {
+			impact = fmt.Sprintf("Replacing pod %s will destroy its tasks and permanently delete their persistent volumes. (Failed to retrieve pod info: %s)", cmd.PodName, err)
+		} else {
+			impact = describePodReplacement(cmd.PodName, infoBytes)
+		}
+		if !client.ConfirmWithName(impact, cmd.PodName, false) {
+			client.PrintMessageAndExit("Pod replace cancelled.")
+			return nil
+		}
+	}
 	body, err := client.HTTPServicePost(fmt.Sprintf("v1/pods/%s/replace", cmd.PodName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintResponseText(body)
 	return nil
@@ -81,4 +155,5 @@ func HandlePodsSection(app *kingpin.Application) {
 
 	replace := pods.Command("replace", "Destroys a given pod and moves it to a new agent").Action(cmd.handleReplace)
 	replace.Arg("pod", "Name of the pod instance to replace").Required().StringVar(&cmd.PodName)
+	replace.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
 }
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribePodReplacement(t *testing.T) {
+	infoBytes, err := ioutil.ReadFile("testdata/responses/scheduler/pod-info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `Replacing pod hello-0 will destroy the following tasks and permanently delete their persistent volumes:
+  Task hello-0-server (TASK_RUNNING) on agent b4a3a2cc-6b0f-4ee9-8a17-c3b1e5e4ac3e-S2
+    Volume hello-container-path: 25 MB (persistence ID d6b2b3f4-8c1e-4a7d-9f2b-5e6c7d8a9b01)
+The pod will be relaunched on a new agent with empty volumes.`
+	assert.Equal(t, expectedOutput, describePodReplacement("hello-0", infoBytes))
+}
+
+func TestDescribePodReplacementMalformed(t *testing.T) {
+	assert.Contains(t, describePodReplacement("hello-0", []byte("{")), "Failed to parse pod info")
+}
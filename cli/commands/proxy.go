@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type proxyHandler struct {
+	Address  string
+	Port     int
+	ReadOnly bool
+}
+
+func (cmd *proxyHandler) handleProxy(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	listenAddress := net.JoinHostPort(cmd.Address, strconv.Itoa(cmd.Port))
+	handler := client.NewServiceProxy(cmd.ReadOnly)
+
+	client.PrintMessage("Forwarding http://%s/ to the %s scheduler API. Press Ctrl-C to exit.", listenAddress, config.ServiceName)
+	if cmd.ReadOnly {
+		client.PrintMessage("Read-only mode: only GET requests will be forwarded.")
+	}
+	err := http.ListenAndServe(listenAddress, handler)
+	if err != nil {
+		client.PrintErrorAndExit(fmt.Errorf("Failed to run proxy on %s: %s", listenAddress, err))
+	}
+	return nil
+}
+
+// HandleProxySection adds the proxy subcommand to the passed in kingpin.Application.
+func HandleProxySection(app *kingpin.Application) {
+	cmd := &proxyHandler{}
+	proxy := app.Command("proxy", "Run a local HTTP server which forwards authenticated requests to the service scheduler API").Action(cmd.handleProxy)
+	proxy.Flag("port", "Local port to listen on").Default("8080").IntVar(&cmd.Port)
+	proxy.Flag("address", "Local address to listen on").Default("127.0.0.1").StringVar(&cmd.Address)
+	proxy.Flag("read-only", "Only forward GET requests, rejecting any requests which may modify the service").BoolVar(&cmd.ReadOnly)
+}
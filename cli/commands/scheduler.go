@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Labels which Cosmos applies to the Marathon apps of installed packages.
+const (
+	packageNameLabel    = "DCOS_PACKAGE_NAME"
+	packageVersionLabel = "DCOS_PACKAGE_VERSION"
+	packageOptionsLabel = "DCOS_PACKAGE_OPTIONS"
+)
+
+type schedulerHandler struct {
+	Force bool
+	Yes   bool
+}
+
+func getSchedulerApp() *client.MarathonApp {
+	appID := client.MarathonAppID(config.ServiceName)
+	app, err := client.GetMarathonApp(appID)
+	if err != nil {
+		client.PrintMessageAndExit(fmt.Sprintf("Failed to retrieve Marathon app %s: %s", appID, err))
+	}
+	if app == nil {
+		client.PrintErrorAndExit(client.NewServiceNotFoundError(config.ServiceName,
+			fmt.Sprintf("No service named '%s' is installed. Specify a different name with '--name=<name>'.", config.ServiceName)))
+	}
+	return app
+}
+
+// getFrameworkID returns the Mesos framework ID reported by the scheduler, or an empty string if the
+// scheduler couldn't be reached or hasn't registered yet.
+func getFrameworkID() string {
+	responseBytes, err := client.HTTPServiceGet("v1/state/frameworkId")
+	if err != nil {
+		if config.Verbose {
+			client.PrintMessage("Unable to retrieve framework ID from scheduler: %s", err)
+		}
+		return ""
+	}
+	return parseIDResponse(responseBytes)
+}
+
+// parseIDResponse returns the ID from a scheduler endpoint such as v1/state/frameworkId or
+// v1/configurations/targetId.
+func parseIDResponse(responseBytes []byte) string {
+	// the scheduler returns a single-element array, but also accept a plain string:
+	var ids []string
+	if err := json.Unmarshal(responseBytes, &ids); err == nil {
+		if len(ids) > 0 {
+			return ids[0]
+		}
+		return ""
+	}
+	var id string
+	if err := json.Unmarshal(responseBytes, &id); err == nil {
+		return id
+	}
+	return strings.TrimSpace(string(responseBytes))
+}
+
+func taskHealth(task client.MarathonTask) string {
+	if len(task.HealthCheckResults) == 0 {
+		return "UNKNOWN"
+	}
+	for _, result := range task.HealthCheckResults {
+		if !result.Alive {
+			return "UNHEALTHY"
+		}
+	}
+	return "HEALTHY"
+}
+
+func taskUptime(task client.MarathonTask, now time.Time) string {
+	startedAt, err := time.Parse(time.RFC3339, task.StartedAt)
+	if err != nil {
+		return "unknown"
+	}
+	return now.Sub(startedAt).Truncate(time.Second).String()
+}
+
+func valueOrNone(value string) string {
+	if len(value) == 0 {
+		return "<none>"
+	}
+	return value
+}
+
+func toSchedulerStatus(app *client.MarathonApp, frameworkID string, now time.Time) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(writer, "Service:\t%s\n", config.ServiceName)
+	fmt.Fprintf(writer, "Marathon app:\t%s\n", app.ID)
+	fmt.Fprintf(writer, "Package:\t%s %s\n", valueOrNone(app.Labels[packageNameLabel]), app.Labels[packageVersionLabel])
+	fmt.Fprintf(writer, "App version:\t%s\n", app.Version)
+	fmt.Fprintf(writer, "Framework ID:\t%s\n", valueOrNone(frameworkID))
+	fmt.Fprintf(writer, "Instances:\t%d/%d running, %d healthy, %d unhealthy, %d staged\n",
+		app.TasksRunning, app.Instances, app.TasksHealthy, app.TasksUnhealthy, app.TasksStaged)
+	deploymentIDs := make([]string, 0, len(app.Deployments))
+	for _, deployment := range app.Deployments {
+		deploymentIDs = append(deploymentIDs, deployment.ID)
+	}
+	fmt.Fprintf(writer, "Deployments:\t%s\n", valueOrNone(strings.Join(deploymentIDs, ", ")))
+	for _, task := range app.Tasks {
+		fmt.Fprintf(writer, "Task:\t%s\n", task.ID)
+		fmt.Fprintf(writer, "  State:\t%s (%s)\n", task.State, taskHealth(task))
+		fmt.Fprintf(writer, "  Agent:\t%s (%s)\n", task.Host, task.SlaveID)
+		fmt.Fprintf(writer, "  Started:\t%s (up %s)\n", task.StartedAt, taskUptime(task, now))
+		if task.Version != app.Version {
+			fmt.Fprintf(writer, "  Version:\t%s (outdated)\n", task.Version)
+		}
+	}
+	if app.LastTaskFailure != nil {
+		fmt.Fprintf(writer, "Last failure:\t%s at %s on %s: %s\n", app.LastTaskFailure.State,
+			app.LastTaskFailure.Timestamp, app.LastTaskFailure.Host, app.LastTaskFailure.Message)
+	}
+	writer.Flush()
+	// Trim extra newline from end:
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (cmd *schedulerHandler) handleStatus(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	app := getSchedulerApp()
+	client.PrintMessage(toSchedulerStatus(app, getFrameworkID(), time.Now()))
+	return nil
+}
+
+func toSchedulerVersion(app *client.MarathonApp) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(writer, "Package name:\t%s\n", valueOrNone(app.Labels[packageNameLabel]))
+	fmt.Fprintf(writer, "Package version:\t%s\n", valueOrNone(app.Labels[packageVersionLabel]))
+	fmt.Fprintf(writer, "App version:\t%s\n", app.Version)
+	for _, task := range app.Tasks {
+		fmt.Fprintf(writer, "Running version:\t%s (%s)\n", task.Version, task.ID)
+	}
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (cmd *schedulerHandler) handleVersion(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	client.PrintMessage(toSchedulerVersion(getSchedulerApp()))
+	return nil
+}
+
+func (cmd *schedulerHandler) handleRestart(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	app := getSchedulerApp()
+	if !client.Confirm(fmt.Sprintf("Restart the scheduler for service '%s' (Marathon app %s)?", config.ServiceName, app.ID), cmd.Yes) {
+		client.PrintMessageAndExit("Scheduler restart cancelled.")
+	}
+	deploymentID, err := client.RestartMarathonApp(app.ID, cmd.Force)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+	}
+	client.PrintMessage("Scheduler restart started (Marathon deployment %s). Please use `dcos %s --name=%s scheduler status` to view progress.",
+		deploymentID, config.ModuleName, config.ServiceName)
+	return nil
+}
+
+// HandleSchedulerSection adds scheduler subcommands to the passed in kingpin.Application.
+func HandleSchedulerSection(app *kingpin.Application) {
+	// scheduler <status, restart, version>
+	cmd := &schedulerHandler{}
+	scheduler := app.Command("scheduler", "View or restart the service scheduler process")
+
+	restart := scheduler.Command("restart", "Restart the scheduler via Marathon, e.g. after editing its environment").Action(cmd.handleRestart)
+	restart.Flag("force", "Cancel any in-progress Marathon deployment of the scheduler").BoolVar(&cmd.Force)
+	restart.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
+
+	scheduler.Command("status", "Display the scheduler's Marathon state, health, uptime and agent").Action(cmd.handleStatus)
+
+	scheduler.Command("version", "Display the package and Marathon app versions of the scheduler").Action(cmd.handleVersion)
+}
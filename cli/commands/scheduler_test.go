@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SchedulerTestSuite) loadFile(filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return data
+}
+
+func (suite *SchedulerTestSuite) loadApp(filename string) *client.MarathonApp {
+	var response struct {
+		App client.MarathonApp `json:"app"`
+	}
+	err := json.Unmarshal(suite.loadFile(filename), &response)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return &response.App
+}
+
+func (suite *SchedulerTestSuite) SetupTest() {
+	config.ModuleName = "hello-world"
+	config.ServiceName = "hello-world"
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
+
+func (suite *SchedulerTestSuite) TestParseIDResponse() {
+	frameworkID := parseIDResponse(suite.loadFile("testdata/responses/scheduler/framework-id.json"))
+	assert.Equal(suite.T(), "b4a3a2cc-6b0f-4ee9-8a17-c3b1e5e4ac3e-0002", frameworkID)
+	assert.Equal(suite.T(), "some-id", parseIDResponse([]byte(`"some-id"`)))
+	assert.Equal(suite.T(), "", parseIDResponse([]byte(`[]`)))
+}
+
+func (suite *SchedulerTestSuite) TestSchedulerStatus() {
+	app := suite.loadApp("testdata/responses/marathon/app-healthy.json")
+	now, _ := time.Parse(time.RFC3339, "2017-07-05T19:25:53.999Z")
+
+	result := toSchedulerStatus(app, "b4a3a2cc-6b0f-4ee9-8a17-c3b1e5e4ac3e-0002", now)
+
+	expectedOutput := suite.loadFile("testdata/output/scheduler-status.txt")
+	assert.Equal(suite.T(), string(expectedOutput), result)
+}
+
+func (suite *SchedulerTestSuite) TestSchedulerVersion() {
+	app := suite.loadApp("testdata/responses/marathon/app-healthy.json")
+
+	expectedOutput := `Package name:    hello-world
+Package version: 1.1.0
+App version:     2017-07-05T18:23:43.391Z
+Running version: 2017-07-05T18:23:43.391Z (hello-world.8fbc4a2b-61ad-11e7-9f4f-2a7b2d5ca26f)`
+	assert.Equal(suite.T(), expectedOutput, toSchedulerVersion(app))
+}
+
+func (suite *SchedulerTestSuite) TestTaskHealth() {
+	assert.Equal(suite.T(), "UNKNOWN", taskHealth(client.MarathonTask{}))
+	assert.Equal(suite.T(), "HEALTHY", taskHealth(client.MarathonTask{
+		HealthCheckResults: []client.MarathonHealthCheckResult{{Alive: true}}}))
+	assert.Equal(suite.T(), "UNHEALTHY", taskHealth(client.MarathonTask{
+		HealthCheckResults: []client.MarathonHealthCheckResult{{Alive: true}, {Alive: false}}}))
+}
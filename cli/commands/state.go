@@ -15,7 +15,7 @@ func (cmd *stateHandler) handleFrameworkID(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/state/frameworkId")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -24,7 +24,7 @@ func (cmd *stateHandler) handleProperties(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet("v1/state/properties")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 
@@ -34,7 +34,7 @@ func (cmd *stateHandler) handleProperty(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServiceGet(fmt.Sprintf("v1/state/properties/%s", cmd.PropertyName))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
@@ -43,7 +43,7 @@ func (cmd *stateHandler) handleRefreshCache(c *kingpin.ParseContext) error {
 	// TODO: figure out KingPin's error handling
 	body, err := client.HTTPServicePut("v1/state/refresh")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	client.PrintJSONBytes(body)
 	return nil
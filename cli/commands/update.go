@@ -1,16 +1,27 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/mesosphere/dcos-commons/cli/client"
 	"github.com/mesosphere/dcos-commons/cli/config"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
-type describeHandler struct{}
+type describeHandler struct {
+	Compare         string
+	IgnoredPaths    []string
+	SchedulerConfig bool
+}
 
 type describeRequest struct {
 	AppID string `json:"appId"`
@@ -32,11 +43,11 @@ func describe() {
 	// TODO: figure out KingPin's error handling
 	requestContent, err := json.Marshal(describeRequest{config.ServiceName})
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	responseBytes, err := client.HTTPCosmosPostJSON("describe", string(requestContent))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	// This attempts to retrieve resolvedOptions from the response. This field is only provided by
 	// Cosmos running on Enterprise DC/OS 1.10 clusters or later.
@@ -52,6 +63,18 @@ func describe() {
 
 func (cmd *describeHandler) handleDescribe(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
+	if len(cmd.Compare) > 0 {
+		ignoredPaths := cmd.IgnoredPaths
+		if len(ignoredPaths) == 0 {
+			ignoredPaths = defaultComparisonIgnoredPaths
+		}
+		compareServices(cmd.Compare, ignoredPaths, cmd.SchedulerConfig)
+		return nil
+	}
+	if cmd.SchedulerConfig {
+		client.PrintMessageAndExit("--scheduler-config requires --compare.")
+		return nil
+	}
 	describe()
 	return nil
 }
@@ -59,20 +82,62 @@ func (cmd *describeHandler) handleDescribe(c *kingpin.ParseContext) error {
 // HandleDescribe adds the describe subcommand to the passed in kingpin.Application.
 func HandleDescribe(app *kingpin.Application) {
 	cmd := &describeHandler{}
-	app.Command("describe", "View the package configuration for this DC/OS service").Action(cmd.handleDescribe)
+	describe := app.Command("describe", "View the package configuration for this DC/OS service").Action(cmd.handleDescribe)
+	describe.Flag("compare", "Show how the package version and options of the service with this name differ from this service").PlaceHolder("OTHER-SERVICE-NAME").StringVar(&cmd.Compare)
+	describe.Flag("ignore", fmt.Sprintf("Field to omit from --compare, in path.to.field form; can be repeated (default: %s)", strings.Join(defaultComparisonIgnoredPaths, ", "))).StringsVar(&cmd.IgnoredPaths)
+	describe.Flag("scheduler-config", "With --compare, also compare the scheduler target configurations").BoolVar(&cmd.SchedulerConfig)
+}
+
+type packageDescription struct {
+	Package struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"package"`
+	ResolvedOptions map[string]interface{} `json:"resolvedOptions"`
+	UpgradesTo      []string               `json:"upgradesTo"`
+	DowngradesTo    []string               `json:"downgradesTo"`
+}
+
+func describePackageBytes(serviceName string) ([]byte, error) {
+	requestContent, _ := json.Marshal(describeRequest{serviceName})
+	return client.HTTPCosmosPostJSON("describe", string(requestContent))
+}
+
+func parsePackageDescription(responseBytes []byte) (*packageDescription, error) {
+	var description packageDescription
+	err := json.Unmarshal(responseBytes, &description)
+	return &description, err
+}
+
+// describePackage returns the Cosmos description of the named service's package.
+func describePackage(serviceName string) *packageDescription {
+	responseBytes, err := describePackageBytes(serviceName)
+	if err != nil {
+		client.PrintErrorAndExit(err)
+	}
+	description, err := parsePackageDescription(responseBytes)
+	checkError(err, responseBytes)
+	return description
 }
 
 type updateHandler struct {
 	UpdateName     string
 	OptionsFile    string
+	SetValues      []string
+	UnsetPaths     []string
 	PackageVersion string
 	ViewStatus     bool
+	Wait           bool
+	WaitTimeout    time.Duration
+	Canary         int
+	Yes            bool
 }
 
 type updateRequest struct {
 	AppID          string                 `json:"appId"`
 	PackageVersion string                 `json:"packageVersion,omitempty"`
 	OptionsJSON    map[string]interface{} `json:"options,omitempty"`
+	Replace        bool                   `json:"replace,omitempty"`
 }
 
 func printPackageVersions() {
@@ -80,7 +145,7 @@ func printPackageVersions() {
 	requestContent, _ := json.Marshal(describeRequest{config.ServiceName})
 	responseBytes, err := client.HTTPCosmosPostJSON("describe", string(requestContent))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
 	packageBytes, err := client.GetValueFromJSONResponse(responseBytes, "package")
 	checkError(err, responseBytes)
@@ -126,45 +191,611 @@ func parseUpdateResponse(responseBytes []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return string(responseJSON["marathonDeploymentId"].(string)), nil
+	deploymentID, ok := responseJSON["marathonDeploymentId"].(string)
+	if !ok {
+		return "", fmt.Errorf("Response is missing a marathonDeploymentId")
+	}
+	return deploymentID, nil
+}
+
+// readOptionsFile loads a JSON options file, or reads the options from stdin if the filename is "-".
+func readOptionsFile(optionsFile string) (map[string]interface{}, error) {
+	var fileBytes []byte
+	var err error
+	if optionsFile == "-" {
+		fileBytes, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		fileBytes, err = ioutil.ReadFile(optionsFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load specified options file %s: %s", optionsFile, err)
+	}
+	optionsJSON, err := client.UnmarshalJSON(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse JSON in specified options file %s: %s", optionsFile, err)
+	}
+	return optionsJSON, nil
+}
+
+// patchOptions applies the options file (if any) and the --set and --unset flags on top of the
+// service's current resolved options, printing the resulting changes. Returns nil if the options
+// couldn't be patched.
+func patchOptions(optionsJSON map[string]interface{}, setValues, unsetPaths []string) map[string]interface{} {
+	description := describePackage(config.ServiceName)
+	if description.ResolvedOptions == nil {
+		client.PrintMessage("Package configuration is not available for service %s.", config.ServiceName)
+		client.PrintMessage("--set and --unset are only available for packages installed with Enterprise DC/OS 1.10 or newer. Use --options with a complete options file instead.")
+		return nil
+	}
+	// work on a copy so that the original options can be diffed afterwards:
+	resolvedBytes, _ := json.Marshal(description.ResolvedOptions)
+	patched, _ := client.UnmarshalJSON(resolvedBytes)
+	if optionsJSON != nil {
+		mergeOptions(patched, optionsJSON)
+	}
+	for _, assignment := range setValues {
+		if err := setOption(patched, assignment); err != nil {
+			client.PrintMessage(err.Error())
+			return nil
+		}
+	}
+	for _, path := range unsetPaths {
+		if err := unsetOption(patched, path); err != nil {
+			client.PrintMessage(err.Error())
+			return nil
+		}
+	}
+	client.PrintMessage(toDiffString("Package options changes", description.ResolvedOptions, patched, nil))
+	return patched
 }
 
-func doUpdate(optionsFile, packageVersion string) {
+// doUpdate submits the update to Cosmos, returning the ID of the resulting Marathon deployment of
+// the scheduler, or an empty string if the update wasn't submitted.
+func doUpdate(optionsFile, packageVersion string, setValues, unsetPaths []string) string {
 	// TODO: figure out KingPin's error handling
 	request := updateRequest{AppID: config.ServiceName}
-	if len(packageVersion) == 0 && len(optionsFile) == 0 {
-		client.PrintMessage("Either --options and/or --package-version must be specified. See --help.")
-		return
+	if len(packageVersion) == 0 && len(optionsFile) == 0 && len(setValues) == 0 && len(unsetPaths) == 0 {
+		client.PrintMessage("Either --options, --set, --unset and/or --package-version must be specified. See --help.")
+		return ""
 	}
 	if len(packageVersion) > 0 {
 		request.PackageVersion = packageVersion
 	}
 	if len(optionsFile) > 0 {
-		fileBytes, err := ioutil.ReadFile(optionsFile)
-		if err != nil {
-			client.PrintMessage("Failed to load specified options file %s: %s", optionsFile, err)
-			return
-		}
-		optionsJSON, err := client.UnmarshalJSON(fileBytes)
+		optionsJSON, err := readOptionsFile(optionsFile)
 		if err != nil {
-			client.PrintMessage("Failed to parse JSON in specified options file %s: %s", optionsFile, err)
-			return
+			client.PrintMessage(err.Error())
+			return ""
 		}
 		request.OptionsJSON = optionsJSON
 	}
+	if len(setValues) > 0 || len(unsetPaths) > 0 {
+		patched := patchOptions(request.OptionsJSON, setValues, unsetPaths)
+		if patched == nil {
+			return ""
+		}
+		// the patched options are complete, so they replace (rather than merge with) the stored options:
+		request.OptionsJSON = patched
+		request.Replace = true
+	}
+	return submitUpdate(request)
+}
+
+// submitUpdate records the service's current configuration so that the update may be rolled back,
+// then sends the update request to Cosmos, returning the ID of the resulting Marathon deployment of
+// the scheduler.
+func submitUpdate(request updateRequest) string {
+	recordUpdate()
 	requestContent, _ := json.Marshal(request)
 	responseBytes, err := client.HTTPCosmosPostJSON("update", string(requestContent))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	}
-	_, err = client.UnmarshalJSON(responseBytes)
+	deploymentID, err := parseUpdateResponse(responseBytes)
 	checkError(err, responseBytes)
 	client.PrintMessage(fmt.Sprintf("Update started. Please use `dcos %s --name=%s update status` to view progress.", config.ModuleName, config.ServiceName))
+	return deploymentID
+}
+
+// waitPollInterval is the delay between queries while waiting for an update to complete.
+var waitPollInterval = 5 * time.Second
+
+// updateWaiter follows an update from the Marathon deployment of the new scheduler through to the
+// completion of the scheduler's update (or deploy) plan, printing progress as it changes.
+type updateWaiter struct {
+	deadline    time.Time
+	lastMessage string
+}
+
+func newUpdateWaiter(timeout time.Duration) *updateWaiter {
+	waiter := &updateWaiter{}
+	if timeout > 0 {
+		waiter.deadline = time.Now().Add(timeout)
+	}
+	return waiter
+}
+
+// progress prints the message if it differs from the previously printed message.
+func (w *updateWaiter) progress(message string) {
+	if message != w.lastMessage {
+		client.PrintMessage(message)
+		w.lastMessage = message
+	}
+}
+
+// planProgress records the plan's state in its local history, and prints a summary of its progress
+// along with the estimated time remaining if the summary has changed.
+func (w *updateWaiter) planProgress(planName string, plan *planInfo) {
+	history := recordPlanObservation(planName, plan)
+	summary := planProgressSummary(planName, plan)
+	if summary != w.lastMessage {
+		client.PrintMessage("%s%s", summary, planETA(history, plan, time.Now()))
+		w.lastMessage = summary
+	}
+}
+
+// sleep waits for the next poll, exiting if the deadline would be exceeded.
+func (w *updateWaiter) sleep(waitingFor string) {
+	if !w.deadline.IsZero() && time.Now().Add(waitPollInterval).After(w.deadline) {
+		client.PrintMessageAndExit(fmt.Sprintf("Timed out waiting for %s.", waitingFor))
+	}
+	time.Sleep(waitPollInterval)
+}
+
+func (w *updateWaiter) waitForDeployment(deploymentID string) {
+	for {
+		deployment, err := client.GetMarathonDeployment(deploymentID)
+		if err != nil {
+			if config.Verbose {
+				client.PrintMessage("Failed to retrieve Marathon deployment %s: %s", deploymentID, err)
+			}
+		} else if deployment == nil {
+			w.progress(fmt.Sprintf("Marathon deployment %s is complete.", deploymentID))
+			return
+		} else {
+			actions := make([]string, 0, len(deployment.CurrentActions))
+			for _, action := range deployment.CurrentActions {
+				actions = append(actions, fmt.Sprintf("%s %s", action.Action, action.App))
+			}
+			w.progress(fmt.Sprintf("Marathon deployment %s: step %d/%d (%s)",
+				deploymentID, deployment.CurrentStep, deployment.TotalSteps, strings.Join(actions, ", ")))
+		}
+		w.sleep(fmt.Sprintf("Marathon deployment %s", deploymentID))
+	}
+}
+
+// waitForPlanName returns the plan which will roll out the update: "update" if the scheduler
+// offers one, or "deploy" otherwise.
+func (w *updateWaiter) waitForPlanName() string {
+	for {
+		planNames, err := getPlanNames()
+		if err == nil {
+			for _, planName := range planNames {
+				if planName == "update" {
+					return planName
+				}
+			}
+			return "deploy"
+		}
+		w.progress("Waiting for the scheduler API to become available...")
+		if config.Verbose {
+			client.PrintMessage("Failed to retrieve plans: %s", err)
+		}
+		w.sleep("the scheduler API")
+	}
+}
+
+// waitForPlan waits for the plan to complete. Returns an error if the plan has errors, or if it has
+// been paused, since a paused plan won't complete until an operator resumes it.
+func (w *updateWaiter) waitForPlan(planName string) error {
+	for {
+		plan, planBytes, err := getPlan(planName)
+		if err != nil {
+			if config.Verbose {
+				client.PrintMessage("Failed to retrieve %s plan: %s", planName, err)
+			}
+		} else {
+			w.planProgress(planName, plan)
+			switch plan.Status {
+			case statusComplete:
+				return nil
+			case statusError:
+				client.PrintMessage(toStatusTree(planName, planBytes))
+				return fmt.Errorf("%s plan has errors", planName)
+			case statusWaiting:
+				return fmt.Errorf("%s plan is paused. Use `dcos %s --name=%s %s` to roll out the remaining steps",
+					planName, config.ModuleName, config.ServiceName, planResumeCommand(planName))
+			}
+		}
+		w.sleep(fmt.Sprintf("the %s plan to complete", planName))
+	}
+}
+
+// planResumeCommand returns the CLI command which resumes the named plan.
+func planResumeCommand(planName string) string {
+	if planName == "deploy" {
+		return "update resume"
+	}
+	return fmt.Sprintf("plan resume %s", planName)
+}
+
+func waitForUpdate(deploymentID string, timeout time.Duration) {
+	waiter := newUpdateWaiter(timeout)
+	waiter.waitForDeployment(deploymentID)
+	planName := waiter.waitForPlanName()
+	if err := waiter.waitForPlan(planName); err != nil {
+		client.PrintMessageAndExit(fmt.Sprintf("Update failed: %s.", err))
+		return
+	}
+	client.PrintMessage("Update complete.")
+}
+
+// stepStarted returns whether a step with the provided status has been started by the scheduler.
+func stepStarted(status string) bool {
+	return status != statusPending && status != statusWaiting
+}
+
+// podInstanceName returns the pod instance deployed by a step, e.g. "hello-0" for "hello-0:[server]".
+func podInstanceName(stepName string) string {
+	if index := strings.Index(stepName, ":["); index > 0 {
+		return stepName[:index]
+	}
+	return stepName
+}
+
+// canarySteps returns the first count steps of the plan which have been started, in plan order.
+func canarySteps(plan *planInfo, count int) []stepInfo {
+	steps := make([]stepInfo, 0, count)
+	for _, phase := range plan.Phases {
+		for _, step := range phase.Steps {
+			if len(steps) < count && stepStarted(step.Status) {
+				steps = append(steps, step)
+			}
+		}
+	}
+	return steps
+}
+
+// waitForCanary follows the plan until count steps have been started, then pauses the plan so that
+// no further steps are started, and waits for the canary steps to finish. Returns the names of the
+// canary steps, or nil if the plan finished before the canary steps were started.
+func (w *updateWaiter) waitForCanary(planName string, count int) ([]string, error) {
+	paused := false
+	for {
+		plan, planBytes, err := getPlan(planName)
+		if err != nil {
+			if config.Verbose {
+				client.PrintMessage("Failed to retrieve %s plan: %s", planName, err)
+			}
+			w.sleep(fmt.Sprintf("the %s plan", planName))
+			continue
+		}
+		w.planProgress(planName, plan)
+		if plan.Status == statusError {
+			client.PrintMessage(toStatusTree(planName, planBytes))
+			return nil, fmt.Errorf("%s plan has errors", planName)
+		}
+		steps := canarySteps(plan, count)
+		if !paused {
+			if plan.Status == statusComplete {
+				return nil, nil
+			}
+			if len(steps) == count {
+				if err := pause(planName, ""); err != nil {
+					return nil, err
+				}
+				paused = true
+				continue
+			}
+		} else {
+			stepNames := make([]string, 0, len(steps))
+			for _, step := range steps {
+				if step.Status != statusComplete {
+					break
+				}
+				stepNames = append(stepNames, step.Name)
+			}
+			if len(stepNames) == len(steps) {
+				return stepNames, nil
+			}
+		}
+		w.sleep(fmt.Sprintf("%d canary step(s) of the %s plan to complete", count, planName))
+	}
+}
+
+type podStatus struct {
+	Name  string `json:"name"`
+	Tasks []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"tasks"`
+}
+
+// toCanaryPodStatus returns a summary of the task states of the pods deployed by the canary steps.
+func toCanaryPodStatus(stepNames []string) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 1, ' ', 0)
+	reported := make(map[string]bool)
+	for _, stepName := range stepNames {
+		podName := podInstanceName(stepName)
+		if reported[podName] {
+			continue
+		}
+		reported[podName] = true
+		responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/pods/%s/status", podName))
+		if err != nil {
+			fmt.Fprintf(writer, "%s:	status unavailable: %s\n", podName, err)
+			continue
+		}
+		var status podStatus
+		if err := json.Unmarshal(responseBytes, &status); err != nil {
+			fmt.Fprintf(writer, "%s:	status unavailable: %s\n", podName, err)
+			continue
+		}
+		for _, task := range status.Tasks {
+			fmt.Fprintf(writer, "%s:	%s\t%s\n", podName, task.Name, task.Status)
+		}
+	}
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// waitForCanaryUpdate follows the update until the requested number of steps have been rolled out,
+// leaving the plan paused so that the operator may check the result before resuming.
+func waitForCanaryUpdate(deploymentID string, count int, timeout time.Duration) {
+	waiter := newUpdateWaiter(timeout)
+	waiter.waitForDeployment(deploymentID)
+	planName := waiter.waitForPlanName()
+	stepNames, err := waiter.waitForCanary(planName, count)
+	if err != nil {
+		client.PrintMessageAndExit(fmt.Sprintf("Update failed: %s.", err))
+		return
+	}
+	if stepNames == nil {
+		client.PrintMessage("Update complete: the %s plan completed before %d canary step(s) were started.", planName, count)
+		return
+	}
+	client.PrintMessage("Canary step(s) complete: %s", strings.Join(stepNames, ", "))
+	client.PrintMessage(toCanaryPodStatus(stepNames))
+	client.PrintMessage("The %s plan is paused. Once the canary pods are healthy, use `dcos %s --name=%s %s` to roll out the remaining steps, or `dcos %s --name=%s update rollback` to revert.",
+		planName, config.ModuleName, config.ServiceName, planResumeCommand(planName), config.ModuleName, config.ServiceName)
+}
+
+// maxRollbackAppVersions limits how far back the scheduler's Marathon app history is searched for a
+// previous package version or options.
+const maxRollbackAppVersions = 50
+
+type rollbackPoint struct {
+	// Source describes when the configuration was in use, e.g. "as of <app version>"
+	Source         string
+	PackageVersion string
+	Options        map[string]interface{}
+	// ConfigID is the scheduler's target configuration at the time, if known
+	ConfigID string
+}
+
+// findRecordedRollbackPoint returns the most recent configuration in the service's local update
+// history which differs from the package version and resolved options currently described by Cosmos,
+// or nil if none was recorded.
+func findRecordedRollbackPoint(description *packageDescription) *rollbackPoint {
+	path := updateHistoryPath()
+	if len(path) == 0 {
+		return nil
+	}
+	history, err := loadUpdateHistory(path)
+	if err != nil {
+		if config.Verbose {
+			client.PrintMessage("Failed to load update history of service %s: %s", config.ServiceName, err)
+		}
+		return nil
+	}
+	record := history.previous(description.Package.Version, description.ResolvedOptions)
+	if record == nil {
+		return nil
+	}
+	return &rollbackPoint{
+		Source:         "before the update at " + record.Time.Format(time.RFC3339),
+		PackageVersion: record.PackageVersion,
+		Options:        record.ResolvedOptions,
+		ConfigID:       record.ConfigID,
+	}
+}
+
+// decodePackageOptions returns the user-provided options which Cosmos stored in the app's labels.
+func decodePackageOptions(app *client.MarathonApp) (map[string]interface{}, error) {
+	encodedOptions := app.Labels[packageOptionsLabel]
+	if len(encodedOptions) == 0 {
+		return make(map[string]interface{}), nil
+	}
+	optionsBytes, err := base64.StdEncoding.DecodeString(encodedOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode %s label of app version %s: %s", packageOptionsLabel, app.Version, err)
+	}
+	return client.UnmarshalJSON(optionsBytes)
+}
+
+// findRollbackPoint searches the history of the scheduler's Marathon app for the most recent version
+// whose package version or options differ from the current version. This is the fallback for updates
+// which weren't recorded locally: Cosmos only describes the currently installed package, but stores
+// the package version and user-provided options of each update in the labels of the app. Returns the
+// current version, and the rollback point or nil if none was found.
+func findRollbackPoint(appID string) (*rollbackPoint, *rollbackPoint, error) {
+	versions, err := client.GetMarathonAppVersions(appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(versions) == 0 {
+		return nil, nil, fmt.Errorf("No versions found for Marathon app %s", appID)
+	}
+	// timestamps sort lexically: most recent first
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	if len(versions) > maxRollbackAppVersions {
+		versions = versions[:maxRollbackAppVersions]
+	}
+
+	var current *rollbackPoint
+	for _, version := range versions {
+		app, err := client.GetMarathonAppVersion(appID, version)
+		if err != nil {
+			return nil, nil, err
+		}
+		options, err := decodePackageOptions(app)
+		if err != nil {
+			return nil, nil, err
+		}
+		point := &rollbackPoint{Source: "as of " + version, PackageVersion: app.Labels[packageVersionLabel], Options: options}
+		if current == nil {
+			current = point
+			continue
+		}
+		if point.PackageVersion != current.PackageVersion || len(diffJSON(current.Options, point.Options, nil)) > 0 {
+			return current, point, nil
+		}
+	}
+	return current, nil, nil
+}
+
+// getSchedulerConfigHistory returns the scheduler's target configuration ID, and the IDs of all the
+// configurations which it has retained.
+func getSchedulerConfigHistory() (string, []string, error) {
+	targetIDBytes, err := client.HTTPServiceGet("v1/configurations/targetId")
+	if err != nil {
+		return "", nil, err
+	}
+	configIDsBytes, err := client.HTTPServiceGet("v1/configurations")
+	if err != nil {
+		return "", nil, err
+	}
+	configIDs, err := client.JSONBytesToArray(configIDsBytes)
+	checkError(err, configIDsBytes)
+	return parseIDResponse(targetIDBytes), configIDs, nil
+}
+
+// printSchedulerConfigRollback prints how the scheduler's target configuration differs from the
+// configuration which was its target at the rollback point, if the scheduler has retained it.
+func printSchedulerConfigRollback(previousID string) {
+	targetID, configIDs, err := getSchedulerConfigHistory()
+	if err != nil {
+		client.PrintMessage("Scheduler configuration history is unavailable: %s", err)
+		return
+	}
+	if previousID == targetID {
+		client.PrintMessage("Scheduler configuration: the target configuration %s is unchanged.", targetID)
+		return
+	}
+	for _, configID := range configIDs {
+		if configID == previousID {
+			title := fmt.Sprintf("Scheduler configuration changes (target %s => previous %s)", targetID, previousID)
+			client.PrintMessage(toDiffString(title, getSchedulerConfig(targetID), getSchedulerConfig(previousID), nil))
+			return
+		}
+	}
+	client.PrintMessage("The scheduler no longer retains its previous configuration %s: the target configuration %s will be replaced once the rollback is deployed.", previousID, targetID)
+}
+
+// printSchedulerConfigDiffs prints how the scheduler's target configuration differs from any other
+// configurations which the scheduler has retained, e.g. those still used by tasks mid-update.
+func printSchedulerConfigDiffs() {
+	targetID, configIDs, err := getSchedulerConfigHistory()
+	if err != nil {
+		client.PrintMessage("Scheduler configuration history is unavailable: %s", err)
+		return
+	}
+	targetConfig := getSchedulerConfig(targetID)
+
+	foundPrevious := false
+	for _, configID := range configIDs {
+		if configID == targetID {
+			continue
+		}
+		foundPrevious = true
+		title := fmt.Sprintf("Scheduler configuration changes (target %s => previous %s)", targetID, configID)
+		client.PrintMessage(toDiffString(title, targetConfig, getSchedulerConfig(configID), nil))
+	}
+	if !foundPrevious {
+		client.PrintMessage("The scheduler has not retained any previous configurations: only the target configuration %s is in use.", targetID)
+	}
+}
+
+func getSchedulerConfig(configID string) map[string]interface{} {
+	responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/configurations/%s", configID))
+	if err != nil {
+		client.PrintErrorAndExit(err)
+	}
+	configJSON, err := client.UnmarshalJSON(responseBytes)
+	checkError(err, responseBytes)
+	return configJSON
+}
+
+func doRollback(yes bool) string {
+	description := describePackage(config.ServiceName)
+	if description.ResolvedOptions == nil {
+		client.PrintMessage("Package configuration is not available for service %s.", config.ServiceName)
+		client.PrintMessageAndExit("dcos %s %s is only available for packages installed with Enterprise DC/OS 1.10 or newer.", config.ModuleName, config.Command)
+		return ""
+	}
+	// prefer the configuration recorded before the last update, falling back to the Marathon app history:
+	current := &rollbackPoint{PackageVersion: description.Package.Version, Options: description.ResolvedOptions}
+	previous := findRecordedRollbackPoint(description)
+	if previous == nil {
+		client.PrintMessage("No configuration of service %s was recorded before an update. Falling back to the package versions and options in the labels of its Marathon app versions.", config.ServiceName)
+		var err error
+		current, previous, err = findRollbackPoint(client.MarathonAppID(config.ServiceName))
+		if err != nil {
+			client.PrintMessageAndExit(fmt.Sprintf("Failed to retrieve update history for service %s: %s", config.ServiceName, err))
+			return ""
+		}
+	}
+	if previous == nil {
+		client.PrintMessageAndExit(fmt.Sprintf("No previous package version or options found for service %s.", config.ServiceName))
+		return ""
+	}
+
+	client.PrintMessage("Rolling back service %s to its configuration %s:", config.ServiceName, previous.Source)
+	client.PrintMessage("Package version: %s => %s", description.Package.Version, previous.PackageVersion)
+	client.PrintMessage(toDiffString("Package options changes", current.Options, previous.Options, nil))
+	if len(previous.ConfigID) > 0 {
+		printSchedulerConfigRollback(previous.ConfigID)
+	} else {
+		printSchedulerConfigDiffs()
+	}
+
+	if !client.Confirm(fmt.Sprintf("Roll back service '%s'?", config.ServiceName), yes) {
+		client.PrintMessageAndExit("Rollback cancelled.")
+		return ""
+	}
+	request := updateRequest{AppID: config.ServiceName, OptionsJSON: previous.Options, Replace: true}
+	if previous.PackageVersion != description.Package.Version {
+		request.PackageVersion = previous.PackageVersion
+	}
+	return submitUpdate(request)
+}
+
+func (cmd *updateHandler) Rollback(c *kingpin.ParseContext) error {
+	config.Command = c.SelectedCommand.FullCommand()
+	deploymentID := doRollback(cmd.Yes)
+	if cmd.Wait && len(deploymentID) > 0 {
+		waitForUpdate(deploymentID, cmd.WaitTimeout)
+	}
+	return nil
 }
 
 func (cmd *updateHandler) UpdateConfiguration(c *kingpin.ParseContext) error {
 	config.Command = c.SelectedCommand.FullCommand()
-	doUpdate(cmd.OptionsFile, cmd.PackageVersion)
+	if cmd.Canary > 0 && cmd.Wait {
+		client.PrintMessageAndExit("--canary and --wait cannot be combined: --canary waits for the canary steps, then pauses the update.")
+		return nil
+	}
+	deploymentID := doUpdate(cmd.OptionsFile, cmd.PackageVersion, cmd.SetValues, cmd.UnsetPaths)
+	if len(deploymentID) == 0 {
+		return nil
+	}
+	if cmd.Canary > 0 {
+		waitForCanaryUpdate(deploymentID, cmd.Canary, cmd.WaitTimeout)
+	} else if cmd.Wait {
+		waitForUpdate(deploymentID, cmd.WaitTimeout)
+	}
 	return nil
 }
 
@@ -174,23 +805,35 @@ func HandleUpdateSection(app *kingpin.Application) {
 	update := app.Command("update", "Updates the package version or configuration for this DC/OS service")
 
 	start := update.Command("start", "Launches an update operation").Action(cmd.UpdateConfiguration)
-	start.Flag("options", "Path to a JSON file that contains customized package installation options").StringVar(&cmd.OptionsFile)
+	start.Flag("options", "Path to a JSON file that contains customized package installation options, or '-' to read from stdin").StringVar(&cmd.OptionsFile)
+	start.Flag("set", "Change a single option on top of the current options, in path.to.field=value form; can be repeated").StringsVar(&cmd.SetValues)
+	start.Flag("unset", "Remove a single option from the current options, in path.to.field form; can be repeated").StringsVar(&cmd.UnsetPaths)
 	start.Flag("package-version", "The desired package version").StringVar(&cmd.PackageVersion)
+	start.Flag("wait", "Wait for the scheduler to be redeployed and for the update to complete, exiting non-zero if it fails").BoolVar(&cmd.Wait)
+	start.Flag("canary", "Pause the update once this many steps have started, then wait for them to complete and report the status of their pods").IntVar(&cmd.Canary)
+	start.Flag("timeout", "Maximum duration to wait with --wait or --canary, or zero to wait indefinitely").Default("0s").DurationVar(&cmd.WaitTimeout)
 
 	planCmd := &planHandler{}
 
 	forceComplete := update.Command("force-complete", "Force complete a specific step in the provided phase").Alias("force").Action(planCmd.handleForceComplete)
-	forceComplete.Arg("phase", "Name or UUID of the phase containing the provided step").Required().StringVar(&planCmd.Phase)
-	forceComplete.Arg("step", "Name or UUID of step to be restarted").Required().StringVar(&planCmd.Step)
+	forceComplete.Arg("phase", "Name or UUID of the phase containing the provided step").StringVar(&planCmd.Phase)
+	forceComplete.Arg("step", "Name or UUID of step to be restarted").StringVar(&planCmd.Step)
+	planCmd.addStepSelectorFlags(forceComplete)
 
 	forceRestart := update.Command("force-restart", "Restart update plan, or specific step in the provided phase").Alias("restart").Action(planCmd.handleForceRestart)
 	forceRestart.Arg("phase", "Name or UUID of the phase containing the provided step").StringVar(&planCmd.Phase)
 	forceRestart.Arg("step", "Name or UUID of step to be restarted").StringVar(&planCmd.Step)
+	planCmd.addStepSelectorFlags(forceRestart)
 
 	update.Command("package-versions", "View a list of available package versions to downgrade or upgrade to").Action(cmd.ViewPackageVersions)
 
 	update.Command("pause", "Pause update plan, or the plan with the provided name, or a specific phase in that plan with the provided name or UUID").Alias("interrupt").Action(planCmd.handlePause)
 
+	rollback := update.Command("rollback", "Revert to the previous package version and options").Action(cmd.Rollback)
+	rollback.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.Yes)
+	rollback.Flag("wait", "Wait for the scheduler to be redeployed and for the rollback to complete, exiting non-zero if it fails").BoolVar(&cmd.Wait)
+	rollback.Flag("timeout", "Maximum duration to wait with --wait, or zero to wait indefinitely").Default("0s").DurationVar(&cmd.WaitTimeout)
+
 	update.Command("resume", "Resume update plan, or the plan with the provided name, or a specific phase in that plan with the provided name or UUID").Alias("continue").Action(planCmd.handleResume)
 
 	status := update.Command("status", "View status of a running update").Alias("show").Action(planCmd.handleStatus)
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/mesosphere/dcos-commons/cli/client"
+	"github.com/mesosphere/dcos-commons/cli/config"
+)
+
+// maxUpdateRecords limits how many updates are retained in the update history of a service.
+const maxUpdateRecords = 20
+
+// updateRecord is the configuration of a service just before an update was submitted: the package
+// version and resolved options described by Cosmos, and the scheduler's target configuration.
+type updateRecord struct {
+	Time            time.Time              `json:"time"`
+	PackageVersion  string                 `json:"packageVersion"`
+	ResolvedOptions map[string]interface{} `json:"resolvedOptions"`
+	ConfigID        string                 `json:"configId,omitempty"`
+}
+
+type updateHistory struct {
+	Updates []*updateRecord `json:"updates"`
+}
+
+// updateHistoryPath returns the file which holds the update history of the current cluster and
+// service, or an empty string if local state is disabled.
+func updateHistoryPath() string {
+	return localStatePath("update-history", "updates")
+}
+
+func loadUpdateHistory(path string) (*updateHistory, error) {
+	history := &updateHistory{}
+	if err := loadLocalState(path, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *updateRecord) sameConfiguration(packageVersion string, resolvedOptions map[string]interface{}) bool {
+	return r.PackageVersion == packageVersion && len(diffJSON(r.ResolvedOptions, resolvedOptions, nil)) == 0
+}
+
+// add appends the record, or only refreshes the most recent record if the configuration is unchanged.
+func (h *updateHistory) add(record *updateRecord) {
+	if count := len(h.Updates); count > 0 {
+		last := h.Updates[count-1]
+		if last.ConfigID == record.ConfigID && last.sameConfiguration(record.PackageVersion, record.ResolvedOptions) {
+			last.Time = record.Time
+			return
+		}
+	}
+	h.Updates = append(h.Updates, record)
+	if len(h.Updates) > maxUpdateRecords {
+		h.Updates = h.Updates[len(h.Updates)-maxUpdateRecords:]
+	}
+}
+
+// previous returns the most recent record whose package version or options differ from those given,
+// or nil if there is none.
+func (h *updateHistory) previous(packageVersion string, resolvedOptions map[string]interface{}) *updateRecord {
+	for i := len(h.Updates) - 1; i >= 0; i-- {
+		if !h.Updates[i].sameConfiguration(packageVersion, resolvedOptions) {
+			return h.Updates[i]
+		}
+	}
+	return nil
+}
+
+// recordUpdate adds the service's current configuration to its local update history before an update
+// is submitted, so that the update can later be rolled back. Failures are only reported in verbose
+// mode, as rollback falls back to the history of the scheduler's Marathon app.
+func recordUpdate() {
+	path := updateHistoryPath()
+	if len(path) == 0 {
+		return
+	}
+	err := func() error {
+		responseBytes, err := describePackageBytes(config.ServiceName)
+		if err != nil {
+			return err
+		}
+		description, err := parsePackageDescription(responseBytes)
+		if err != nil {
+			return err
+		}
+		if description.ResolvedOptions == nil {
+			// Cosmos doesn't describe the options of packages installed before Enterprise DC/OS 1.10
+			return nil
+		}
+		record := &updateRecord{
+			Time:            time.Now().UTC(),
+			PackageVersion:  description.Package.Version,
+			ResolvedOptions: description.ResolvedOptions,
+		}
+		if targetIDBytes, err := client.HTTPServiceGet("v1/configurations/targetId"); err == nil {
+			record.ConfigID = parseIDResponse(targetIDBytes)
+		}
+		history, err := loadUpdateHistory(path)
+		if err != nil {
+			return err
+		}
+		history.add(record)
+		return saveLocalState(path, history)
+	}()
+	if err != nil && config.Verbose {
+		client.PrintMessage("Failed to record configuration of service %s before update: %s", config.ServiceName, err)
+	}
+}
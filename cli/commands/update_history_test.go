@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateHistory(t *testing.T) {
+	options := func(count float64) map[string]interface{} {
+		return map[string]interface{}{"hello": map[string]interface{}{"count": count}}
+	}
+	start := time.Date(2017, 7, 3, 9, 0, 0, 0, time.UTC)
+	history := &updateHistory{}
+	assert.Nil(t, history.previous("v1.0", options(1)))
+
+	history.add(&updateRecord{Time: start, PackageVersion: "v0.9", ResolvedOptions: options(1), ConfigID: "a"})
+	// an unchanged configuration only refreshes the most recent record:
+	history.add(&updateRecord{Time: start.Add(time.Hour), PackageVersion: "v0.9", ResolvedOptions: options(1), ConfigID: "a"})
+	assert.Equal(t, 1, len(history.Updates))
+	assert.Equal(t, start.Add(time.Hour), history.Updates[0].Time)
+
+	history.add(&updateRecord{Time: start.Add(2 * time.Hour), PackageVersion: "v1.0", ResolvedOptions: options(1), ConfigID: "b"})
+	history.add(&updateRecord{Time: start.Add(3 * time.Hour), PackageVersion: "v1.0", ResolvedOptions: options(3), ConfigID: "c"})
+	assert.Equal(t, 3, len(history.Updates))
+
+	// the most recent record which differs from the current configuration:
+	assert.Equal(t, "c", history.previous("v1.0", options(1)).ConfigID)
+	assert.Equal(t, "b", history.previous("v1.0", options(3)).ConfigID)
+	assert.Equal(t, "c", history.previous("v1.1", options(3)).ConfigID)
+
+	for i := 0; i < maxUpdateRecords; i++ {
+		history.add(&updateRecord{PackageVersion: "v1.0", ResolvedOptions: options(float64(i)), ConfigID: "d"})
+	}
+	assert.Equal(t, maxUpdateRecords, len(history.Updates))
+}
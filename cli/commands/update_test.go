@@ -6,7 +6,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/mesosphere/dcos-commons/cli/client"
 	"github.com/mesosphere/dcos-commons/cli/config"
@@ -19,6 +21,7 @@ type UpdateTestSuite struct {
 	server         *httptest.Server
 	requestBody    []byte
 	responseBody   []byte
+	responses      map[string][]byte
 	capturedOutput bytes.Buffer
 }
 
@@ -27,6 +30,10 @@ func (suite *UpdateTestSuite) printRecorder(format string, a ...interface{}) (n
 	return 0, nil // this is probably sub-optimal in the general sense
 }
 
+func (suite *UpdateTestSuite) errorRecorder(err error) {
+	suite.printRecorder("%s", err.Error())
+}
+
 func (suite *UpdateTestSuite) loadFile(filename string) []byte {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -44,7 +51,11 @@ func (suite *UpdateTestSuite) exampleHandler(w http.ResponseWriter, r *http.Requ
 	suite.requestBody = requestBody
 
 	w.WriteHeader(http.StatusOK)
-	w.Write(suite.responseBody)
+	if responseBody, ok := suite.responses[r.URL.Path]; ok {
+		w.Write(responseBody)
+	} else {
+		w.Write(suite.responseBody)
+	}
 }
 
 func (suite *UpdateTestSuite) SetupSuite() {
@@ -54,6 +65,7 @@ func (suite *UpdateTestSuite) SetupSuite() {
 	// reassign printing functions to allow us to check output
 	client.PrintMessage = suite.printRecorder
 	client.PrintMessageAndExit = suite.printRecorder
+	client.PrintErrorAndExit = suite.errorRecorder
 }
 
 func (suite *UpdateTestSuite) SetupTest() {
@@ -63,6 +75,7 @@ func (suite *UpdateTestSuite) SetupTest() {
 }
 
 func (suite *UpdateTestSuite) TearDownTest() {
+	suite.responses = nil
 	suite.capturedOutput.Reset()
 	suite.server.Close()
 }
@@ -109,7 +122,7 @@ func (suite *UpdateTestSuite) TestUpdatePackageVersionsNoPackageVersions() {
 
 func (suite *UpdateTestSuite) TestUpdateConfiguration() {
 	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
-	doUpdate("testdata/input/config.json", "")
+	doUpdate("testdata/input/config.json", "", nil, nil)
 
 	// assert request is what we expect
 	expectedRequest := suite.loadFile("testdata/requests/update-configuration.json")
@@ -122,7 +135,7 @@ func (suite *UpdateTestSuite) TestUpdateConfiguration() {
 
 func (suite *UpdateTestSuite) TestUpdatePackageVersion() {
 	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
-	doUpdate("", "stub-universe")
+	doUpdate("", "stub-universe", nil, nil)
 
 	// assert request is what we expect
 	expectedRequest := suite.loadFile("testdata/requests/update-package-version.json")
@@ -135,7 +148,7 @@ func (suite *UpdateTestSuite) TestUpdatePackageVersion() {
 
 func (suite *UpdateTestSuite) TestUpdateConfigurationAndPackageVersion() {
 	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
-	doUpdate("testdata/input/config.json", "stub-universe")
+	doUpdate("testdata/input/config.json", "stub-universe", nil, nil)
 
 	// assert request is what we expect
 	expectedRequest := suite.loadFile("testdata/requests/update.json")
@@ -147,19 +160,235 @@ func (suite *UpdateTestSuite) TestUpdateConfigurationAndPackageVersion() {
 }
 
 func (suite *UpdateTestSuite) TestUpdateWithWrongPath() {
-	doUpdate("testdata/input/emptyASDF.json", "")
+	doUpdate("testdata/input/emptyASDF.json", "", nil, nil)
 	expectedOutput := "Failed to load specified options file testdata/input/emptyASDF.json: open testdata/input/emptyASDF.json: no such file or directory\n"
 	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
 }
 
 func (suite *UpdateTestSuite) TestUpdateWithEmptyFile() {
-	doUpdate("testdata/input/empty.json", "")
+	doUpdate("testdata/input/empty.json", "", nil, nil)
 	expectedOutput := "Failed to parse JSON in specified options file testdata/input/empty.json: unexpected end of JSON input\n"
 	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
 }
 
 func (suite *UpdateTestSuite) TestUpdateWithMalformedFile() {
-	doUpdate("testdata/input/malformed.json", "")
+	doUpdate("testdata/input/malformed.json", "", nil, nil)
 	expectedOutput := "Failed to parse JSON in specified options file testdata/input/malformed.json: unexpected end of JSON input\n"
 	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
 }
+
+func (suite *UpdateTestSuite) TestUpdateSetOptions() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
+	suite.responses = map[string][]byte{
+		"/cosmos/service/describe": suite.loadFile("testdata/responses/cosmos/1.10/enterprise/describe.json"),
+	}
+	doUpdate("", "", []string{"hello.count=3", "service.user=nobody"}, []string{"service.secret_name"})
+
+	// assert request is what we expect
+	expectedRequest := suite.loadFile("testdata/requests/update-set-options.json")
+	assert.JSONEq(suite.T(), string(expectedRequest), string(suite.requestBody))
+
+	// assert CLI output is what we expect
+	expectedOutput := suite.loadFile("testdata/output/update-set-options.txt")
+	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestUpdateSetOptionsWrongType() {
+	suite.responses = map[string][]byte{
+		"/cosmos/service/describe": suite.loadFile("testdata/responses/cosmos/1.10/enterprise/describe.json"),
+	}
+	deploymentID := doUpdate("", "", []string{"hello.count=three"}, nil)
+	assert.Equal(suite.T(), "", deploymentID)
+	expectedOutput := "Value 'three' for hello.count is not a valid number\n"
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestUpdateSetOptionsNoResolvedOptions() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/open/describe.json")
+	deploymentID := doUpdate("", "", []string{"hello.count=3"}, nil)
+	assert.Equal(suite.T(), "", deploymentID)
+	assert.Contains(suite.T(), suite.capturedOutput.String(), "Package configuration is not available for service hello-world.")
+}
+
+func (suite *UpdateTestSuite) TestWaitForUpdate() {
+	waitPollInterval = time.Millisecond
+	suite.responses = map[string][]byte{
+		"/marathon/v2/deployments":             suite.loadFile("testdata/responses/marathon/deployments-empty.json"),
+		"/service/hello-world/v1/plans":        suite.loadFile("testdata/responses/scheduler/plans.json"),
+		"/service/hello-world/v1/plans/update": suite.loadFile("testdata/responses/scheduler/plan-status-complete.json"),
+	}
+	waitForUpdate("2f89a170-f91f-4a54-ae49-ba3aa4bcc178", 0)
+
+	expectedOutput := `Marathon deployment 2f89a170-f91f-4a54-ae49-ba3aa4bcc178 is complete.
+update (COMPLETE): 2/2 steps complete
+Update complete.
+`
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestWaitForUpdateDeployPlan() {
+	waitPollInterval = time.Millisecond
+	suite.responses = map[string][]byte{
+		"/marathon/v2/deployments":             suite.loadFile("testdata/responses/marathon/deployments-empty.json"),
+		"/service/hello-world/v1/plans":        suite.loadFile("testdata/responses/scheduler/plans-no-update.json"),
+		"/service/hello-world/v1/plans/deploy": suite.loadFile("testdata/responses/scheduler/plan-status-complete.json"),
+	}
+	waitForUpdate("2f89a170-f91f-4a54-ae49-ba3aa4bcc178", 0)
+
+	expectedOutput := `Marathon deployment 2f89a170-f91f-4a54-ae49-ba3aa4bcc178 is complete.
+deploy (COMPLETE): 2/2 steps complete
+Update complete.
+`
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestWaitForUpdatePausedPlan() {
+	waitPollInterval = time.Millisecond
+	suite.responses = map[string][]byte{
+		"/marathon/v2/deployments":             suite.loadFile("testdata/responses/marathon/deployments-empty.json"),
+		"/service/hello-world/v1/plans":        suite.loadFile("testdata/responses/scheduler/plans.json"),
+		"/service/hello-world/v1/plans/update": suite.loadFile("testdata/responses/scheduler/plan-status-waiting.json"),
+	}
+	waitForUpdate("2f89a170-f91f-4a54-ae49-ba3aa4bcc178", 0)
+
+	expectedOutput := `Marathon deployment 2f89a170-f91f-4a54-ae49-ba3aa4bcc178 is complete.
+update (WAITING): 1/2 steps complete
+Update failed: update plan is paused. Use ` + "`dcos hello-world --name=hello-world plan resume update`" + ` to roll out the remaining steps.
+`
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestWaitForCanaryUpdate() {
+	waitPollInterval = time.Millisecond
+	suite.responses = map[string][]byte{
+		"/marathon/v2/deployments":                       suite.loadFile("testdata/responses/marathon/deployments-empty.json"),
+		"/service/hello-world/v1/plans":                  suite.loadFile("testdata/responses/scheduler/plans.json"),
+		"/service/hello-world/v1/plans/update":           suite.loadFile("testdata/responses/scheduler/plan-status.json"),
+		"/service/hello-world/v1/plans/update/interrupt": suite.loadFile("testdata/responses/scheduler/interrupt.json"),
+		"/service/hello-world/v1/pods/kafka-0/status":    suite.loadFile("testdata/responses/scheduler/pod-status.json"),
+	}
+	waitForCanaryUpdate("2f89a170-f91f-4a54-ae49-ba3aa4bcc178", 1, 0)
+
+	expectedOutput := `Marathon deployment 2f89a170-f91f-4a54-ae49-ba3aa4bcc178 is complete.
+update (IN_PROGRESS): 1/6 steps complete, active: kafka-1:[broker] (IN_PROGRESS)
+"update" plan has been paused.
+Canary step(s) complete: kafka-0:[broker]
+kafka-0: kafka-0-broker TASK_RUNNING
+The update plan is paused. Once the canary pods are healthy, use ` + "`dcos hello-world --name=hello-world plan resume update`" + ` to roll out the remaining steps, or ` + "`dcos hello-world --name=hello-world update rollback`" + ` to revert.
+`
+	assert.Equal(suite.T(), expectedOutput, suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestCanarySteps() {
+	plan, err := parsePlan(suite.loadFile("testdata/responses/scheduler/plan-status.json"))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	steps := canarySteps(plan, 3)
+	assert.Equal(suite.T(), 2, len(steps))
+	assert.Equal(suite.T(), "kafka-0:[broker]", steps[0].Name)
+	assert.Equal(suite.T(), "kafka-1:[broker]", steps[1].Name)
+	assert.Equal(suite.T(), 1, len(canarySteps(plan, 1)))
+
+	assert.Equal(suite.T(), "kafka-1", podInstanceName("kafka-1:[broker]"))
+	assert.Equal(suite.T(), "custom-step", podInstanceName("custom-step"))
+}
+
+func (suite *UpdateTestSuite) TestParseUpdateResponse() {
+	deploymentID, err := parseUpdateResponse(suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json"))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "2f89a170-f91f-4a54-ae49-ba3aa4bcc178", deploymentID)
+
+	_, err = parseUpdateResponse([]byte(`{}`))
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *UpdateTestSuite) TestRollback() {
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
+	suite.responses = map[string][]byte{
+		"/cosmos/service/describe":                                                    suite.loadFile("testdata/responses/cosmos/1.10/enterprise/describe.json"),
+		"/marathon/v2/apps/hello-world/versions":                                      suite.loadFile("testdata/responses/marathon/app-versions.json"),
+		"/marathon/v2/apps/hello-world/versions/2017-07-05T18:23:43.391Z":             suite.loadFile("testdata/responses/marathon/app-version-current.json"),
+		"/marathon/v2/apps/hello-world/versions/2017-07-04T10:00:00.000Z":             suite.loadFile("testdata/responses/marathon/app-version-restarted.json"),
+		"/marathon/v2/apps/hello-world/versions/2017-07-03T09:00:00.000Z":             suite.loadFile("testdata/responses/marathon/app-version-previous.json"),
+		"/service/hello-world/v1/configurations":                                      suite.loadFile("testdata/responses/scheduler/configurations.json"),
+		"/service/hello-world/v1/configurations/targetId":                             suite.loadFile("testdata/responses/scheduler/configurations-target-id.json"),
+		"/service/hello-world/v1/configurations/f5c2a8b4-7e0d-4c3f-a7f9-0e6d3b2a1c02": suite.loadFile("testdata/responses/scheduler/configuration-target.json"),
+		"/service/hello-world/v1/configurations/b1b7ec1e-3b43-4a4b-9a2a-1d8f2c4d1e01": suite.loadFile("testdata/responses/scheduler/configuration-previous.json"),
+	}
+	deploymentID := doRollback(true)
+	assert.Equal(suite.T(), "2f89a170-f91f-4a54-ae49-ba3aa4bcc178", deploymentID)
+
+	// assert request is what we expect
+	expectedRequest := suite.loadFile("testdata/requests/rollback.json")
+	assert.JSONEq(suite.T(), string(expectedRequest), string(suite.requestBody))
+
+	// assert CLI output is what we expect
+	expectedOutput := suite.loadFile("testdata/output/rollback.txt")
+	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
+}
+
+func (suite *UpdateTestSuite) TestRollbackNoPreviousVersion() {
+	suite.responses = map[string][]byte{
+		"/cosmos/service/describe":                                        suite.loadFile("testdata/responses/cosmos/1.10/enterprise/describe.json"),
+		"/marathon/v2/apps/hello-world/versions":                          []byte(`{"versions": ["2017-07-04T10:00:00.000Z", "2017-07-05T18:23:43.391Z"]}`),
+		"/marathon/v2/apps/hello-world/versions/2017-07-05T18:23:43.391Z": suite.loadFile("testdata/responses/marathon/app-version-current.json"),
+		"/marathon/v2/apps/hello-world/versions/2017-07-04T10:00:00.000Z": suite.loadFile("testdata/responses/marathon/app-version-restarted.json"),
+	}
+	// the lack of a previous configuration is a generic failure, rather than a missing service:
+	client.PrintErrorAndExit = func(err error) { suite.T().Errorf("Unexpected error: %s", err) }
+	defer func() { client.PrintErrorAndExit = suite.errorRecorder }()
+	assert.Equal(suite.T(), "", doRollback(true))
+	assert.Contains(suite.T(), suite.capturedOutput.String(), "No previous package version or options found for service hello-world.\n")
+}
+
+func (suite *UpdateTestSuite) TestRollbackRecorded() {
+	stateDir, err := ioutil.TempDir("", "update-history")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	defer os.RemoveAll(stateDir)
+	defer func() { config.StateDir = "" }()
+	config.StateDir = stateDir
+
+	// the configuration before an update, which differs from the described v1.0 in hello.count:
+	describeBytes := suite.loadFile("testdata/responses/cosmos/1.10/enterprise/describe.json")
+	recorded, err := parsePackageDescription(describeBytes)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	recorded.ResolvedOptions["hello"].(map[string]interface{})["count"] = 3.0
+	path := updateHistoryPath()
+	history := &updateHistory{}
+	history.add(&updateRecord{
+		Time:            time.Date(2017, 7, 3, 9, 0, 0, 0, time.UTC),
+		PackageVersion:  "v0.9",
+		ResolvedOptions: recorded.ResolvedOptions,
+		ConfigID:        "b1b7ec1e-3b43-4a4b-9a2a-1d8f2c4d1e01",
+	})
+	assert.NoError(suite.T(), saveLocalState(path, history))
+
+	suite.responseBody = suite.loadFile("testdata/responses/cosmos/1.10/enterprise/update.json")
+	suite.responses = map[string][]byte{
+		"/cosmos/service/describe":                                                    describeBytes,
+		"/service/hello-world/v1/configurations":                                      suite.loadFile("testdata/responses/scheduler/configurations.json"),
+		"/service/hello-world/v1/configurations/targetId":                             suite.loadFile("testdata/responses/scheduler/configurations-target-id.json"),
+		"/service/hello-world/v1/configurations/f5c2a8b4-7e0d-4c3f-a7f9-0e6d3b2a1c02": suite.loadFile("testdata/responses/scheduler/configuration-target.json"),
+		"/service/hello-world/v1/configurations/b1b7ec1e-3b43-4a4b-9a2a-1d8f2c4d1e01": suite.loadFile("testdata/responses/scheduler/configuration-previous.json"),
+	}
+	deploymentID := doRollback(true)
+	assert.Equal(suite.T(), "2f89a170-f91f-4a54-ae49-ba3aa4bcc178", deploymentID)
+
+	// the recorded package version and resolved options replace the current ones:
+	expectedRequest := suite.loadFile("testdata/requests/rollback-recorded.json")
+	assert.JSONEq(suite.T(), string(expectedRequest), string(suite.requestBody))
+	expectedOutput := suite.loadFile("testdata/output/rollback-recorded.txt")
+	assert.Equal(suite.T(), string(expectedOutput), suite.capturedOutput.String())
+
+	// the configuration before the rollback is recorded in turn, so that the rollback may be reverted:
+	history, err = loadUpdateHistory(path)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(history.Updates))
+	assert.Equal(suite.T(), "v1.0", history.Updates[1].PackageVersion)
+	assert.Equal(suite.T(), "f5c2a8b4-7e0d-4c3f-a7f9-0e6d3b2a1c02", history.Updates[1].ConfigID)
+}
@@ -22,6 +22,19 @@ var (
 	// TLSCACertPath represents the path to a certificate to use when speaking to a DC/OS cluster.
 	TLSCACertPath string
 
+	// StateDir is where the CLI keeps local state, such as the history of observed plans. Local state
+	// isn't recorded if this is unset.
+	StateDir string
+
+	// AuditCollectorURL is an optional HTTP endpoint which is sent each entry written to the audit log.
+	AuditCollectorURL string
+
+	// NonInteractive skips confirmation prompts for destructive commands, e.g. for automation.
+	NonInteractive bool
+
+	// ErrorFormat is the format in which errors are printed to stderr: "text" or "json".
+	ErrorFormat string
+
 	// Verbose will print additional messages to aid with debugging if set to true.
 	Verbose bool
 )
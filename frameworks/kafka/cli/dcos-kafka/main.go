@@ -29,7 +29,7 @@ type BrokerHandler struct {
 func (cmd *BrokerHandler) runList(c *kingpin.ParseContext) error {
 	responseBytes, err := client.HTTPServiceGet("v1/brokers")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -38,7 +38,7 @@ func (cmd *BrokerHandler) runList(c *kingpin.ParseContext) error {
 func (cmd *BrokerHandler) runView(c *kingpin.ParseContext) error {
 	responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/brokers/%s", cmd.broker))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -61,6 +61,7 @@ func handleBrokerSection(app *kingpin.Application) {
 
 type TopicHandler struct {
 	topic               string
+	yes                 bool
 	createPartitions    int
 	createReplication   int
 	offsetsTime         string
@@ -71,7 +72,7 @@ type TopicHandler struct {
 func (cmd *TopicHandler) runList(c *kingpin.ParseContext) error {
 	responseBytes, err := client.HTTPServiceGet("v1/topics")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -80,7 +81,7 @@ func (cmd *TopicHandler) runList(c *kingpin.ParseContext) error {
 func (cmd *TopicHandler) runDescribe(c *kingpin.ParseContext) error {
 	responseBytes, err := client.HTTPServiceGet(fmt.Sprintf("v1/topics/%s", cmd.topic))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -92,7 +93,7 @@ func (cmd *TopicHandler) runCreate(c *kingpin.ParseContext) error {
 	query.Set("replication", strconv.FormatInt(int64(cmd.createReplication), 10))
 	responseBytes, err := client.HTTPServicePutQuery(fmt.Sprintf("v1/topics/%s", cmd.topic), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -110,7 +111,7 @@ func (cmd *TopicHandler) runUnavailablePartitions(c *kingpin.ParseContext) error
 func (cmd *TopicHandler) runUnderReplicatedPartitions(c *kingpin.ParseContext) error {
 	responseBytes, err := client.HTTPServiceGet("v1/topics/under_replicated_partitions")
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -122,7 +123,7 @@ func (cmd *TopicHandler) runPartitions(c *kingpin.ParseContext) error {
 	query.Set("partitions", strconv.FormatInt(int64(cmd.partitionCount), 10))
 	responseBytes, err := client.HTTPServicePutQuery(fmt.Sprintf("v1/topics/%s/operation/partitions", cmd.topic), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -133,16 +134,21 @@ func (cmd *TopicHandler) runProducerTest(c *kingpin.ParseContext) error {
 	query.Set("messages", strconv.FormatInt(int64(cmd.produceMessageCount), 10))
 	responseBytes, err := client.HTTPServicePutQuery(fmt.Sprintf("v1/topics/%s/operation/producer-test", cmd.topic), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
 	return nil
 }
 func (cmd *TopicHandler) runDelete(c *kingpin.ParseContext) error {
+	impact := fmt.Sprintf("Deleting topic %s will permanently delete all of its partitions and messages.", cmd.topic)
+	if !client.ConfirmWithName(impact, cmd.topic, cmd.yes) {
+		client.PrintMessageAndExit("Topic delete cancelled.")
+		return nil
+	}
 	responseBytes, err := client.HTTPServiceDelete(fmt.Sprintf("v1/topics/%s", cmd.topic))
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -166,7 +172,7 @@ func (cmd *TopicHandler) runOffsets(c *kingpin.ParseContext) error {
 	query.Set("time", strconv.FormatInt(timeVal, 10))
 	responseBytes, err := client.HTTPServiceGetQuery(fmt.Sprintf("v1/topics/%s/offsets", cmd.topic), query.Encode())
 	if err != nil {
-		client.PrintMessageAndExit(err.Error())
+		client.PrintErrorAndExit(err)
 	} else {
 		client.PrintJSONBytes(responseBytes)
 	}
@@ -187,7 +193,8 @@ func handleTopicSection(app *kingpin.Application) {
 	delete := topic.Command(
 		"delete",
 		"Deletes an existing topic").Action(cmd.runDelete)
-	delete.Arg("topic", "The topic to delete").StringVar(&cmd.topic)
+	delete.Arg("topic", "The topic to delete").Required().StringVar(&cmd.topic)
+	delete.Flag("yes", "Skip the confirmation prompt").BoolVar(&cmd.yes)
 
 	describe := topic.Command(
 		"describe",
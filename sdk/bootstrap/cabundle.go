@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CA bundle for non-Java runtimes
+
+// caExpiryWarning is how far ahead of the CA's expiry a warning is logged.
+const caExpiryWarning = time.Duration(30*24) * time.Hour
+
+// systemCABundlePaths are the locations of the system CA bundle across common distributions.
+var systemCABundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian/Ubuntu/Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // RHEL/CentOS/Fedora
+	"/etc/ssl/ca-bundle.pem",             // OpenSUSE
+	"/etc/pki/tls/cacert.pem",            // OpenELEC
+	"/etc/ssl/cert.pem",                  // macOS/Alpine
+}
+
+// caBundleEnvVars are the envvars which point OpenSSL, Python and Node at a CA bundle.
+var caBundleEnvVars = []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "NODE_EXTRA_CA_CERTS"}
+
+// caBundleArgs describes the PEM CA bundle to write for non-Java runtimes.
+type caBundleArgs struct {
+	// Path of the combined PEM bundle. Empty means disabled.
+	path string
+	// Path of an env file exporting caBundleEnvVars, or empty for none
+	envFile string
+	// Whether to include the system CAs in the bundle, rather than only the cluster CA
+	includeSystem bool
+	// PEM cluster CA, e.g. $MESOS_SANDBOX/.ssl/ca.crt
+	caPath string
+}
+
+// verifyCACertificates checks that each certificate is a currently valid CA, warning if any expire soon.
+func verifyCACertificates(certs []*x509.Certificate, caPath string) error {
+	now := time.Now()
+	for _, cert := range certs {
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("CA certificate '%s' in '%s' isn't valid until %s", cert.Subject, caPath, cert.NotBefore)
+		}
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("CA certificate '%s' in '%s' expired at %s", cert.Subject, caPath, cert.NotAfter)
+		}
+		if !cert.IsCA {
+			log.Printf("Certificate '%s' in '%s' isn't marked as a CA", cert.Subject, caPath)
+		}
+		if now.Add(caExpiryWarning).After(cert.NotAfter) {
+			log.Printf("CA certificate '%s' in '%s' expires soon, at %s", cert.Subject, caPath, cert.NotAfter)
+		}
+	}
+	return nil
+}
+
+// systemCABundle returns the content and path of the first system CA bundle found, or an error if
+// none are found.
+func systemCABundle() ([]byte, string, error) {
+	for _, bundlePath := range systemCABundlePaths {
+		data, err := ioutil.ReadFile(bundlePath)
+		if err == nil {
+			return data, bundlePath, nil
+		}
+	}
+	return nil, "", fmt.Errorf("No system CA bundle found in any of: %s", systemCABundlePaths)
+}
+
+// shellQuote single-quotes the value for use in a shell script, escaping any single quotes within it.
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// installCABundle writes a PEM bundle with the cluster CA (and optionally the system CAs), and
+// optionally an env file which points SSL_CERT_FILE, REQUESTS_CA_BUNDLE and NODE_EXTRA_CA_CERTS at
+// it, e.g. for "source ca-bundle.env" in a sidecar's cmd.
+func installCABundle(args caBundleArgs) {
+	if exists, _ := isFile(args.caPath); !exists {
+		log.Printf("No CA found at '%s'. Skipping CA bundle installation.", args.caPath)
+		return
+	}
+	caCerts, err := readPEMCertificates(args.caPath)
+	if err != nil {
+		log.Fatalf("Failed to read CA for CA bundle: %s", err)
+	}
+	if err = verifyCACertificates(caCerts, args.caPath); err != nil {
+		log.Fatalf("Invalid CA for CA bundle: %s", err)
+	}
+
+	var bundle bytes.Buffer
+	if args.includeSystem {
+		systemBundle, systemPath, err := systemCABundle()
+		if err != nil {
+			log.Fatalf("Failed to include system CAs in CA bundle: %s. Use -ca-bundle-system=false to only include the cluster CA.", err)
+		}
+		log.Printf("Including system CAs from '%s' in CA bundle", systemPath)
+		bundle.Write(bytes.TrimRight(systemBundle, "\n"))
+		bundle.WriteString("\n")
+	}
+	for _, cert := range caCerts {
+		fmt.Fprintf(&bundle, "# %s\n", cert.Subject)
+		pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+
+	bundlePath, err := filepath.Abs(args.path)
+	if err != nil {
+		log.Fatalf("Invalid CA bundle path '%s': %s", args.path, err)
+	}
+	if err = writeFileAtomic(bundlePath, bundle.Bytes(), writeArgs{mode: os.FileMode(0644), uid: -1, gid: -1}); err != nil {
+		log.Fatalf("Failed to write CA bundle '%s': %s", bundlePath, err)
+	}
+	log.Printf("Wrote CA bundle with %d cluster CA certificate(s): %s", len(caCerts), bundlePath)
+
+	if len(args.envFile) == 0 {
+		return
+	}
+	var env bytes.Buffer
+	for _, key := range caBundleEnvVars {
+		fmt.Fprintf(&env, "export %s=%s\n", key, shellQuote(bundlePath))
+	}
+	if err = writeFileAtomic(args.envFile, env.Bytes(), writeArgs{mode: os.FileMode(0644), uid: -1, gid: -1}); err != nil {
+		log.Fatalf("Failed to write CA bundle env file '%s': %s", args.envFile, err)
+	}
+	log.Printf("Wrote CA bundle env file: %s", args.envFile)
+}
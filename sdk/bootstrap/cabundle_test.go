@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCACertificates(t *testing.T) {
+	now := time.Now()
+	valid, _ := newValidTestCertificate(t, "Valid CA", true, nil, nil)
+	expiringSoon, _ := newTestCertificate(t, "Expiring CA", true, now.Add(-time.Hour), now.Add(24*time.Hour), nil, nil)
+	notCA, _ := newValidTestCertificate(t, "Not a CA", false, nil, nil)
+	expired, _ := newTestCertificate(t, "Expired CA", true, now.Add(-48*time.Hour), now.Add(-24*time.Hour), nil, nil)
+	notYetValid, _ := newTestCertificate(t, "Future CA", true, now.Add(24*time.Hour), now.Add(48*time.Hour), nil, nil)
+
+	// expiring soon and non-CA certificates are only warned about:
+	assert.NoError(t, verifyCACertificates([]*x509.Certificate{valid, expiringSoon, notCA}, "ca.crt"))
+
+	err := verifyCACertificates([]*x509.Certificate{valid, expired}, "ca.crt")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CA certificate 'CN=Expired CA' in 'ca.crt' expired at")
+
+	err = verifyCACertificates([]*x509.Certificate{notYetValid}, "ca.crt")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CA certificate 'CN=Future CA' in 'ca.crt' isn't valid until")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "'/mnt/mesos/sandbox/ca-bundle.crt'", shellQuote("/mnt/mesos/sandbox/ca-bundle.crt"))
+	assert.Equal(t, `'/tmp/it'\''s $HOME/"ca".crt'`, shellQuote(`/tmp/it's $HOME/"ca".crt`))
+}
+
+func TestInstallCABundle(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "it's a $dir")
+	ca, _ := newValidTestCertificate(t, "DC/OS Root CA", true, nil, nil)
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	writeTestCertificates(t, caPath, ca)
+
+	args := caBundleArgs{
+		path:    filepath.Join(dir, "ca-bundle.crt"),
+		envFile: filepath.Join(dir, "ca-bundle.env"),
+		caPath:  caPath,
+	}
+	installCABundle(args)
+
+	bundle, err := readPEMCertificates(args.path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bundle))
+	assert.True(t, bundle[0].Equal(ca))
+
+	env, err := ioutil.ReadFile(args.envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(env), "export "))
+
+	// the env file is sourced as-is, without expanding anything in the path:
+	out, err := exec.Command("sh", "-c", `. "$0" && echo "$SSL_CERT_FILE|$REQUESTS_CA_BUNDLE|$NODE_EXTRA_CA_CERTS"`, args.envFile).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, args.path+"|"+args.path+"|"+args.path+"\n", string(out))
+}
+
+func TestInstallCABundleWithoutCA(t *testing.T) {
+	dir := t.TempDir()
+	args := caBundleArgs{
+		path:   filepath.Join(dir, "ca-bundle.crt"),
+		caPath: filepath.Join(dir, "missing.crt"),
+	}
+	installCABundle(args)
+	exists, _ := isFile(args.path)
+	assert.False(t, exists)
+}
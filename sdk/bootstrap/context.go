@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// structured template context
+
+// templateContextArgs configures the typed template context, which is exposed to templates in
+// addition to the flat envvars.
+type templateContextArgs struct {
+	// Whether to build the typed context
+	enabled bool
+	// Envvars whose values are split into lists
+	listVars []string
+	// Separator for splitting listVars
+	listSeparator string
+	// Envvars whose values are parsed as JSON
+	jsonVars []string
+}
+
+// readEnvMap returns all envvars as a map of name => value.
+func readEnvMap() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		keyVal := strings.SplitN(entry, "=", 2) // entry: "key=val"
+		env[keyVal[0]] = keyVal[1]
+	}
+	return env
+}
+
+// listItems converts a list into section-friendly items, where each item has its value along with
+// its index and whether it's the first or last item, e.g. for "{{#list}}{{value}}{{^last}},{{/last}}{{/list}}".
+// Object entries are copied with index/first/last added, leaving the original objects unchanged.
+func listItems(values []interface{}) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(values))
+	for i, value := range values {
+		item := make(map[string]interface{})
+		if object, ok := value.(map[string]interface{}); ok {
+			for key, field := range object {
+				item[key] = field
+			}
+		} else {
+			item["value"] = value
+		}
+		item["index"] = i
+		item["first"] = i == 0
+		item["last"] = i == len(values)-1
+		items = append(items, item)
+	}
+	return items
+}
+
+// toSectionValue converts parsed JSON into values which mustache can iterate over.
+func toSectionValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			typedValue[key] = toSectionValue(child)
+		}
+		return typedValue
+	case []interface{}:
+		for i, child := range typedValue {
+			typedValue[i] = toSectionValue(child)
+		}
+		return listItems(typedValue)
+	default:
+		return value
+	}
+}
+
+// buildTemplateContext returns the typed template context:
+//   - env: all envvars, where listVars are lists and jsonVars are nested values, e.g. {{env.X}}
+//   - task: this task's ip, name, hostname, pod_index and framework_host, e.g. {{task.ip}}
+func buildTemplateContext(args templateContextArgs, env map[string]string, taskIP string) (map[string]interface{}, error) {
+	envContext := make(map[string]interface{})
+	for key, value := range env {
+		envContext[key] = value
+	}
+	for _, key := range args.listVars {
+		value, ok := env[key]
+		if !ok {
+			return nil, fmt.Errorf("Missing envvar from -template-list-vars: %s", key)
+		}
+		split := splitAndClean(value, args.listSeparator)
+		values := make([]interface{}, 0, len(split))
+		for _, entry := range split {
+			values = append(values, entry)
+		}
+		envContext[key] = listItems(values)
+	}
+	for _, key := range args.jsonVars {
+		value, ok := env[key]
+		if !ok {
+			return nil, fmt.Errorf("Missing envvar from -template-json-vars: %s", key)
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("Failed to parse JSON in envvar %s: %s", key, err)
+		}
+		envContext[key] = toSectionValue(parsed)
+	}
+
+	taskContext := map[string]interface{}{
+		"ip":             taskIP,
+		"name":           env["TASK_NAME"],
+		"pod_index":      env[podInstanceIndexEnv],
+		"framework_host": env["FRAMEWORK_HOST"],
+	}
+	if len(env["TASK_NAME"]) > 0 && len(env["FRAMEWORK_HOST"]) > 0 {
+		taskContext["hostname"] = fmt.Sprintf("%s.%s", env["TASK_NAME"], env["FRAMEWORK_HOST"])
+	}
+
+	return map[string]interface{}{
+		"env":  envContext,
+		"task": taskContext,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListItems(t *testing.T) {
+	items := listItems([]interface{}{"a", "b", "c"})
+	assert.Equal(t, []map[string]interface{}{
+		{"value": "a", "index": 0, "first": true, "last": false},
+		{"value": "b", "index": 1, "first": false, "last": false},
+		{"value": "c", "index": 2, "first": false, "last": true},
+	}, items)
+}
+
+func TestListItemsCopiesObjects(t *testing.T) {
+	object := map[string]interface{}{"host": "broker-0", "port": 9092.0}
+	items := listItems([]interface{}{object})
+	assert.Equal(t, []map[string]interface{}{
+		{"host": "broker-0", "port": 9092.0, "index": 0, "first": true, "last": true},
+	}, items)
+	// the original object isn't modified:
+	assert.Equal(t, map[string]interface{}{"host": "broker-0", "port": 9092.0}, object)
+}
+
+func TestBuildTemplateContext(t *testing.T) {
+	args := templateContextArgs{
+		enabled:       true,
+		listVars:      []string{"BROKERS"},
+		listSeparator: ",",
+		jsonVars:      []string{"LISTENERS"},
+	}
+	env := map[string]string{
+		"BROKERS":            "broker-0, broker-1",
+		"LISTENERS":          `[{"name": "internal", "port": 9092}]`,
+		"TASK_NAME":          "kafka-0-broker",
+		"FRAMEWORK_HOST":     "kafka.autoip.dcos.thisdcos.directory",
+		"POD_INSTANCE_INDEX": "0",
+	}
+	context, err := buildTemplateContext(args, env, "10.0.0.1")
+	assert.NoError(t, err)
+
+	envContext := context["env"].(map[string]interface{})
+	assert.Equal(t, "kafka-0-broker", envContext["TASK_NAME"])
+	brokers := envContext["BROKERS"].([]map[string]interface{})
+	assert.Equal(t, 2, len(brokers))
+	assert.Equal(t, "broker-1", brokers[1]["value"])
+	listeners := envContext["LISTENERS"].([]map[string]interface{})
+	assert.Equal(t, "internal", listeners[0]["name"])
+	assert.Equal(t, true, listeners[0]["last"])
+
+	assert.Equal(t, map[string]interface{}{
+		"ip":             "10.0.0.1",
+		"name":           "kafka-0-broker",
+		"pod_index":      "0",
+		"framework_host": "kafka.autoip.dcos.thisdcos.directory",
+		"hostname":       "kafka-0-broker.kafka.autoip.dcos.thisdcos.directory",
+	}, context["task"])
+
+	_, err = buildTemplateContext(templateContextArgs{enabled: true, jsonVars: []string{"LISTENERS"}},
+		map[string]string{"LISTENERS": "{"}, "10.0.0.1")
+	assert.Error(t, err)
+	_, err = buildTemplateContext(templateContextArgs{enabled: true, listVars: []string{"MISSING"}}, env, "10.0.0.1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// template fetch
+
+const (
+	// Envvar prefix for the expected checksum of the template advertised by the matching
+	// CONFIG_TEMPLATE_<name>, e.g. "CONFIG_CHECKSUM_<name>=sha256:<hex>"
+	templateChecksumPrefix = "CONFIG_CHECKSUM_"
+	// Sandbox directory where the scheduler has the Mesos fetcher download templates. Used as a
+	// fallback when fetching templates by URL fails.
+	templateDownloadDir = "config-templates"
+)
+
+// fetchRetryDelay is the delay between attempts to fetch a template.
+var fetchRetryDelay = time.Duration(1) * time.Second
+
+// fetchArgs describes how templates advertised as URLs are downloaded.
+type fetchArgs struct {
+	// Number of attempts to make before falling back to the sandbox copy
+	attempts int
+	// Timeout for each attempt
+	timeout time.Duration
+	// CA bundle for verifying https URLs. Empty means $MESOS_SANDBOX/.ssl/ca.crt if it exists.
+	caBundle string
+	// Whether to use the sandbox copy in config-templates/ if fetching fails
+	fallback bool
+}
+
+// isTemplateURL returns whether the template source is a URL, e.g. the scheduler's
+// "http://<scheduler>/v1/artifacts/template/<config-id>/<pod>/<task>/<name>", rather than a path
+// within the sandbox.
+func isTemplateURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// templateEnvKey returns the envvar for a setting of the template advertised by templateKey,
+// e.g. CONFIG_TEMPLATE_<name> => CONFIG_ENGINE_<name>.
+func templateEnvKey(prefix string, templateKey string) string {
+	return prefix + strings.TrimPrefix(templateKey, configTemplatePrefix)
+}
+
+// newFetchClient returns an HTTP client which trusts the system CAs along with the provided CA
+// bundle, or the DC/OS CA from the sandbox if no bundle is provided.
+func newFetchClient(args fetchArgs) (*http.Client, error) {
+	caBundle := args.caBundle
+	if len(caBundle) == 0 {
+		sandboxCA := filepath.Join(os.Getenv("MESOS_SANDBOX"), ".ssl", "ca.crt")
+		if exists, _ := isFile(sandboxCA); exists {
+			caBundle = sandboxCA
+		}
+	}
+	client := &http.Client{Timeout: args.timeout}
+	if len(caBundle) == 0 {
+		return client, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := ioutil.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA bundle '%s': %s", caBundle, err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in CA bundle '%s'", caBundle)
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	return client, nil
+}
+
+// fetchTemplateOnce makes a single attempt to download the template. Errors which retrying won't
+// fix are flagged as permanent.
+func fetchTemplateOnce(client *http.Client, rawURL string, templateMaxBytes int64) ([]byte, bool, error) {
+	response, err := client.Get(rawURL)
+	if err != nil {
+		return nil, false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		// 4xx: the template doesn't exist in the scheduler. 5xx: the scheduler may be restarting.
+		permanent := response.StatusCode >= 400 && response.StatusCode < 500
+		return nil, permanent, fmt.Errorf("got response '%s'", response.Status)
+	}
+	var reader io.Reader = response.Body
+	if templateMaxBytes != 0 {
+		reader = io.LimitReader(response.Body, templateMaxBytes+1)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if templateMaxBytes != 0 && int64(len(data)) > templateMaxBytes {
+		return nil, true, fmt.Errorf("content exceeds maximum %d bytes", templateMaxBytes)
+	}
+	return data, false, nil
+}
+
+// fetchTemplate downloads the template, retrying transient failures.
+func fetchTemplate(rawURL string, source string, templateMaxBytes int64, args fetchArgs) ([]byte, error) {
+	client, err := newFetchClient(args)
+	if err != nil {
+		return nil, err
+	}
+	attempts := args.attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		data, permanent, err := fetchTemplateOnce(client, rawURL, templateMaxBytes)
+		if err == nil {
+			log.Printf("Fetched template from %s at '%s' (%d bytes)", source, rawURL, len(data))
+			return data, nil
+		}
+		if permanent || attempt >= attempts {
+			return nil, fmt.Errorf("attempt %d of %d failed: %s", attempt, attempts, err)
+		}
+		if verbose {
+			log.Printf("Attempt %d of %d to fetch '%s' failed: %s", attempt, attempts, rawURL, err)
+		}
+		time.Sleep(fetchRetryDelay)
+	}
+}
+
+// verifyChecksum checks the data against an expected checksum of the form "sha256:<hex>", or just
+// "<hex>". Empty means no verification.
+func verifyChecksum(data []byte, expected string) error {
+	if len(expected) == 0 {
+		return nil
+	}
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	if strings.Contains(expected, ":") {
+		algoSum := strings.SplitN(expected, ":", 2)
+		if algoSum[0] != "sha256" {
+			return fmt.Errorf("unsupported checksum algorithm '%s': expected sha256", algoSum[0])
+		}
+		expected = algoSum[1]
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("sha256 checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// loadTemplate returns the content of the template, which is either downloaded from a URL or read
+// from a path within the sandbox. If downloading fails, the copy which the Mesos fetcher downloaded
+// into config-templates/ is used instead.
+func loadTemplate(src string, source string, templateMaxBytes int64, checksum string, args fetchArgs) []byte {
+	if !isTemplateURL(src) {
+		data := openTemplate(src, source, templateMaxBytes)
+		if err := verifyChecksum(data, checksum); err != nil {
+			log.Fatalf("Failed to verify template from %s at '%s': %s", source, src, err)
+		}
+		return data
+	}
+
+	parsed, err := url.Parse(src)
+	if err != nil {
+		log.Fatalf("Invalid template URL from %s: '%s': %s", source, src, err)
+	}
+	data, err := fetchTemplate(src, source, templateMaxBytes, args)
+	if err == nil {
+		err = verifyChecksum(data, checksum)
+		if err == nil {
+			return data
+		}
+	}
+	if !args.fallback {
+		log.Fatalf("Failed to fetch template from %s at '%s': %s", source, src, err)
+	}
+
+	// The config name is the last element of the artifact URL, matching the name of the fetched copy:
+	fallbackPath := path.Join(templateDownloadDir, path.Base(parsed.Path))
+	log.Printf("Failed to fetch template from %s at '%s': %s. Falling back to sandbox copy at '%s'.",
+		source, src, err, fallbackPath)
+	data = openTemplate(fallbackPath, source, templateMaxBytes)
+	if err := verifyChecksum(data, checksum); err != nil {
+		log.Fatalf("Failed to verify template from %s at '%s': %s", source, fallbackPath, err)
+	}
+	return data
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("port=9092\n")
+	sum := sha256Hex("port=9092\n")
+
+	assert.NoError(t, verifyChecksum(data, ""))
+	assert.NoError(t, verifyChecksum(data, "sha256:"+sum))
+	assert.NoError(t, verifyChecksum(data, sum))
+	assert.NoError(t, verifyChecksum(data, " SHA256:"+strings.ToUpper(sum)+"\n"))
+
+	assert.EqualError(t, verifyChecksum([]byte("port=9093\n"), "sha256:"+sum),
+		"sha256 checksum mismatch: expected "+sum+", got "+sha256Hex("port=9093\n"))
+	assert.EqualError(t, verifyChecksum(data, "md5:d41d8cd98f00b204e9800998ecf8427e"),
+		"unsupported checksum algorithm 'md5': expected sha256")
+}
+
+func newTemplateServer(requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		switch r.URL.Path {
+		case "/v1/artifacts/template/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/v1/artifacts/template/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/v1/artifacts/template/flaky":
+			if *requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("port=9092\n"))
+		default:
+			w.Write([]byte("port=9092\n"))
+		}
+	}))
+}
+
+func TestFetchTemplateOnce(t *testing.T) {
+	requests := 0
+	server := newTemplateServer(&requests)
+	defer server.Close()
+	client := server.Client()
+	url := server.URL + "/v1/artifacts/template/server.properties"
+
+	data, permanent, err := fetchTemplateOnce(client, url, 0)
+	assert.NoError(t, err)
+	assert.False(t, permanent)
+	assert.Equal(t, "port=9092\n", string(data))
+
+	// content of exactly the maximum size is accepted, while one more byte is rejected:
+	data, _, err = fetchTemplateOnce(client, url, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "port=9092\n", string(data))
+	_, permanent, err = fetchTemplateOnce(client, url, 9)
+	assert.EqualError(t, err, "content exceeds maximum 9 bytes")
+	assert.True(t, permanent)
+
+	// 4xx won't be fixed by retrying, while 5xx may be:
+	_, permanent, err = fetchTemplateOnce(client, server.URL+"/v1/artifacts/template/missing", 0)
+	assert.EqualError(t, err, "got response '404 Not Found'")
+	assert.True(t, permanent)
+	_, permanent, err = fetchTemplateOnce(client, server.URL+"/v1/artifacts/template/unavailable", 0)
+	assert.EqualError(t, err, "got response '503 Service Unavailable'")
+	assert.False(t, permanent)
+}
+
+func TestFetchTemplateRetries(t *testing.T) {
+	fetchRetryDelay = time.Millisecond
+	defer func() { fetchRetryDelay = time.Duration(1) * time.Second }()
+	args := fetchArgs{attempts: 3, timeout: time.Duration(5) * time.Second}
+
+	requests := 0
+	server := newTemplateServer(&requests)
+	defer server.Close()
+
+	data, err := fetchTemplate(server.URL+"/v1/artifacts/template/flaky", "test", 0, args)
+	assert.NoError(t, err)
+	assert.Equal(t, "port=9092\n", string(data))
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	_, err = fetchTemplate(server.URL+"/v1/artifacts/template/unavailable", "test", 0, args)
+	assert.EqualError(t, err, "attempt 3 of 3 failed: got response '503 Service Unavailable'")
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	_, err = fetchTemplate(server.URL+"/v1/artifacts/template/missing", "test", 0, args)
+	assert.EqualError(t, err, "attempt 1 of 3 failed: got response '404 Not Found'")
+	assert.Equal(t, 1, requests)
+}
+
+func TestLoadTemplateFallback(t *testing.T) {
+	sandbox := t.TempDir()
+	prevSandbox, hadSandbox := os.LookupEnv("MESOS_SANDBOX")
+	os.Setenv("MESOS_SANDBOX", sandbox)
+	defer func() {
+		if hadSandbox {
+			os.Setenv("MESOS_SANDBOX", prevSandbox)
+		} else {
+			os.Unsetenv("MESOS_SANDBOX")
+		}
+	}()
+	assert.NoError(t, os.MkdirAll(filepath.Join(sandbox, templateDownloadDir), 0755))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(sandbox, templateDownloadDir, "server.properties"), []byte("port=9093\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(sandbox, templateDownloadDir, "missing"), []byte("port=9094\n"), 0644))
+
+	requests := 0
+	server := newTemplateServer(&requests)
+	defer server.Close()
+	args := fetchArgs{attempts: 1, timeout: time.Duration(5) * time.Second, fallback: true}
+	url := server.URL + "/v1/artifacts/template/server.properties"
+
+	// the fetched content matches the checksum:
+	data := loadTemplate(url, "test", 0, "sha256:"+sha256Hex("port=9092\n"), args)
+	assert.Equal(t, "port=9092\n", string(data))
+
+	// the fetched content doesn't match the checksum, but the sandbox copy does:
+	data = loadTemplate(url, "test", 0, "sha256:"+sha256Hex("port=9093\n"), args)
+	assert.Equal(t, "port=9093\n", string(data))
+
+	// the fetch fails:
+	data = loadTemplate(server.URL+"/v1/artifacts/template/missing", "test", 0, "", args)
+	assert.Equal(t, "port=9094\n", string(data))
+
+	// paths within the sandbox are read directly:
+	data = loadTemplate(filepath.Join(templateDownloadDir, "server.properties"), "test", 0, "", args)
+	assert.Equal(t, "port=9093\n", string(data))
+}
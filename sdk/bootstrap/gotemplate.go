@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v2"
+)
+
+// go text/template engine
+
+const (
+	// Envvar prefix for selecting the engine of the template advertised by the matching
+	// CONFIG_TEMPLATE_<name>, e.g. "CONFIG_ENGINE_<name>=go"
+	templateEnginePrefix = "CONFIG_ENGINE_"
+
+	mustacheEngine = "mustache"
+	goEngine       = "go"
+
+	// Helper which is appended to each action that outputs a value, see emptyMissingValues()
+	goTemplateEmptyIfMissing = "emptyIfMissing"
+)
+
+// goTemplateExtensions are the template filename extensions which default to the go engine.
+var goTemplateExtensions = []string{".tmpl", ".gotmpl"}
+
+// templateEngine returns the engine for rendering the template at srcPath: either the engine
+// explicitly provided via marker (e.g. from CONFIG_ENGINE_<name>), or else the engine implied by
+// the template's extension, with mustache as the default.
+func templateEngine(srcPath string, marker string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(marker)) {
+	case "":
+		break
+	case mustacheEngine:
+		return mustacheEngine, nil
+	case goEngine:
+		return goEngine, nil
+	default:
+		return "", fmt.Errorf("Unsupported template engine '%s': expected '%s' or '%s'", marker, mustacheEngine, goEngine)
+	}
+	extension := strings.ToLower(filepath.Ext(srcPath))
+	for _, goExtension := range goTemplateExtensions {
+		if extension == goExtension {
+			return goEngine, nil
+		}
+	}
+	return mustacheEngine, nil
+}
+
+// goTemplateData returns the data passed to go templates: the additional context (if any), with
+// envvars taking precedence, e.g. {{.TASK_NAME}} or {{.task.ip}}.
+func goTemplateData(envMap map[string]string, extraContext map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(envMap)+len(extraContext))
+	for key, value := range extraContext {
+		data[key] = value
+	}
+	for key, value := range envMap {
+		data[key] = value
+	}
+	return data
+}
+
+// renderGoTemplate renders a go text/template. Relative paths passed to 'include' are resolved
+// against dir, matching mustache's handling of partials. In strict mode, keys which are output
+// directly must be defined, while keys passed to 'default' or within conditional bodies may be
+// missing. Missing keys render as empty strings, matching mustache, rather than go's "<no value>".
+func renderGoTemplate(content string, name string, dir string, envMap map[string]string, extraContext map[string]interface{}, strict bool) (string, error) {
+	tmpl, err := template.New(name).Funcs(goTemplateFuncs(dir)).Parse(content)
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{goTemplateEmptyIfMissing: emptyIfMissing})
+	data := goTemplateData(envMap, extraContext)
+	if strict {
+		fields := newGoTemplateFields(tmpl)
+		undefined := make([]string, 0)
+		for name := range fields.required {
+			if _, ok := data[name]; !ok {
+				undefined = append(undefined, name)
+			}
+		}
+		if len(undefined) > 0 {
+			sort.Strings(undefined)
+			return "", fmt.Errorf("Template references %d undefined key(s): %s", len(undefined), strings.Join(undefined, ", "))
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			emptyMissingValues(t.Tree.Root)
+		}
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// emptyIfMissing returns an empty string for missing keys, which text/template would otherwise
+// output as "<no value>", and any other value as-is.
+func emptyIfMissing(value interface{}) interface{} {
+	if value == nil {
+		return ""
+	}
+	return value
+}
+
+// emptyMissingValues pipes the value of each action which outputs one through emptyIfMissing, e.g.
+// {{.X}} is rendered as {{.X | emptyIfMissing}}. Missing keys can't be detected in the output, as
+// "<no value>" may also be intended, e.g. {{printf "%s" "<no value>"}}.
+func emptyMissingValues(node parse.Node) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			emptyMissingValues(child)
+		}
+	case *parse.ActionNode:
+		// actions which declare variables, e.g. {{$x := .X}}, don't output anything:
+		pipe := typedNode.Pipe
+		if len(pipe.Decl) == 0 {
+			pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      pipe.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(goTemplateEmptyIfMissing).SetPos(pipe.Pos)},
+			})
+		}
+	case *parse.IfNode:
+		emptyMissingValues(typedNode.List)
+		emptyMissingValues(typedNode.ElseList)
+	case *parse.RangeNode:
+		emptyMissingValues(typedNode.List)
+		emptyMissingValues(typedNode.ElseList)
+	case *parse.WithNode:
+		emptyMissingValues(typedNode.List)
+		emptyMissingValues(typedNode.ElseList)
+	}
+}
+
+// goTemplateFields are the keys referenced by a go template.
+type goTemplateFields struct {
+	// All referenced keys, with their full path, e.g. "X.y" for {{.X.y}}
+	referenced map[string]bool
+	// Top-level keys whose values are output directly, rather than via 'default' or as a condition,
+	// e.g. "X" for {{.X.y}}
+	required map[string]bool
+}
+
+func newGoTemplateFields(tmpl *template.Template) goTemplateFields {
+	fields := goTemplateFields{referenced: make(map[string]bool), required: make(map[string]bool)}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			fields.walk(t.Tree.Root, true, true)
+		}
+	}
+	return fields
+}
+
+// walk collects the keys referenced by the node. Within range/with bodies, '.' is no longer the
+// root, so only '$.X' references are collected there. Keys within if/with/range bodies aren't
+// required, since the bodies may not be rendered, e.g. {{if .TLS_ENABLED}}{{.TLS_PORT}}{{end}}.
+func (f goTemplateFields) walk(node parse.Node, root bool, required bool) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			f.walk(child, root, required)
+		}
+	case *parse.ActionNode:
+		f.walk(typedNode.Pipe, root, required)
+	case *parse.IfNode:
+		f.walk(typedNode.Pipe, root, false)
+		f.walk(typedNode.List, root, false)
+		f.walk(typedNode.ElseList, root, false)
+	case *parse.RangeNode:
+		f.walk(typedNode.Pipe, root, false)
+		f.walk(typedNode.List, false, false)
+		f.walk(typedNode.ElseList, root, false)
+	case *parse.WithNode:
+		f.walk(typedNode.Pipe, root, false)
+		f.walk(typedNode.List, false, false)
+		f.walk(typedNode.ElseList, root, false)
+	case *parse.TemplateNode:
+		f.walk(typedNode.Pipe, root, required)
+	case *parse.PipeNode:
+		if typedNode == nil {
+			return
+		}
+		// keys are optional when passed to 'default' or piped into it, e.g. {{.X | default "1"}},
+		// so commands are walked from the last so that a 'default' applies to any before it:
+		cmdRequired := required
+		for i := len(typedNode.Cmds) - 1; i >= 0; i-- {
+			cmd := typedNode.Cmds[i]
+			if len(cmd.Args) > 0 {
+				if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "default" {
+					cmdRequired = false
+				}
+			}
+			for _, arg := range cmd.Args {
+				f.walk(arg, root, cmdRequired)
+			}
+		}
+	case *parse.FieldNode:
+		if root {
+			f.add(typedNode.Ident, required)
+		}
+	case *parse.VariableNode:
+		if typedNode.Ident[0] == "$" && len(typedNode.Ident) > 1 {
+			f.add(typedNode.Ident[1:], required)
+		}
+	case *parse.ChainNode:
+		f.walk(typedNode.Node, root, required)
+	}
+}
+
+func (f goTemplateFields) add(path []string, required bool) {
+	f.referenced[strings.Join(path, ".")] = true
+	if required {
+		f.required[path[0]] = true
+	}
+}
+
+// parseGoTemplateFields returns the keys referenced by a go template.
+func parseGoTemplateFields(content string) (goTemplateFields, error) {
+	tmpl, err := template.New("").Funcs(goTemplateFuncs("")).Parse(content)
+	if err != nil {
+		return goTemplateFields{}, err
+	}
+	return newGoTemplateFields(tmpl), nil
+}
+
+// goTemplateFuncs returns the helper functions available to go templates:
+//   - default, required: {{default "512" .HEAP_MB}}, {{required "KAFKA_PORT is required" .KAFKA_PORT}}
+//   - join, split: {{join "," .list}}, {{range split "," .HOSTS}}...{{end}}
+//   - b64enc, b64dec, toJson, toYaml: {{toJson .task}}, {{b64enc .PASSWORD}}
+//   - add, sub, mul, div, mod, min, max: {{div (mul .MEMORY 3) 4}}, where strings are parsed as integers
+//   - include: {{include "jvm.options"}}, the content of a file relative to the rendered file's directory
+func goTemplateFuncs(dir string) template.FuncMap {
+	return template.FuncMap{
+		"default":  defaultValue,
+		"required": requiredValue,
+		"join":     joinValues,
+		"split": func(sep string, value string) []string {
+			return splitAndClean(value, sep)
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"b64dec": func(value string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return string(decoded), err
+		},
+		"toJson": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+		"toYaml": func(value interface{}) (string, error) {
+			encoded, err := yaml.Marshal(value)
+			return strings.TrimSuffix(string(encoded), "\n"), err
+		},
+		"add": arithmetic(func(a, b int64) (int64, error) { return a + b, nil }),
+		"sub": arithmetic(func(a, b int64) (int64, error) { return a - b, nil }),
+		"mul": arithmetic(func(a, b int64) (int64, error) { return a * b, nil }),
+		"div": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a / b, nil
+		}),
+		"mod": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a % b, nil
+		}),
+		"min": arithmetic(func(a, b int64) (int64, error) {
+			if a < b {
+				return a, nil
+			}
+			return b, nil
+		}),
+		"max": arithmetic(func(a, b int64) (int64, error) {
+			if a > b {
+				return a, nil
+			}
+			return b, nil
+		}),
+		"include": func(includePath string) (string, error) {
+			if !filepath.IsAbs(includePath) {
+				includePath = filepath.Join(dir, includePath)
+			}
+			data, err := ioutil.ReadFile(includePath)
+			return string(data), err
+		},
+	}
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return reflected.Len() == 0
+	case reflect.Bool:
+		return !reflected.Bool()
+	}
+	return false
+}
+
+// defaultValue returns the value, or the default if the value is missing or empty.
+func defaultValue(defaultVal interface{}, value interface{}) interface{} {
+	if isEmptyValue(value) {
+		return defaultVal
+	}
+	return value
+}
+
+// requiredValue returns the value, or an error with the provided message if it's missing or empty.
+func requiredValue(message string, value interface{}) (interface{}, error) {
+	if isEmptyValue(value) {
+		return nil, fmt.Errorf("%s", message)
+	}
+	return value, nil
+}
+
+// joinValues joins a list of values, where list entries from the typed template context are
+// joined by their 'value'.
+func joinValues(sep string, values interface{}) (string, error) {
+	switch typedValues := values.(type) {
+	case []string:
+		return strings.Join(typedValues, sep), nil
+	case []interface{}:
+		entries := make([]string, 0, len(typedValues))
+		for _, value := range typedValues {
+			entries = append(entries, fmt.Sprint(value))
+		}
+		return strings.Join(entries, sep), nil
+	case []map[string]interface{}:
+		entries := make([]string, 0, len(typedValues))
+		for _, item := range typedValues {
+			entries = append(entries, fmt.Sprint(item["value"]))
+		}
+		return strings.Join(entries, sep), nil
+	case string:
+		return typedValues, nil
+	default:
+		return "", fmt.Errorf("join: unsupported list type %T", values)
+	}
+}
+
+// toInt64 converts a template value to an integer, parsing strings such as envvar values.
+func toInt64(value interface{}) (int64, error) {
+	switch typedValue := value.(type) {
+	case int:
+		return int64(typedValue), nil
+	case int64:
+		return typedValue, nil
+	case float64:
+		return int64(typedValue), nil
+	case string:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(typedValue), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got '%s'", typedValue)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", value)
+	}
+}
+
+func arithmetic(op func(a, b int64) (int64, error)) func(a, b interface{}) (int64, error) {
+	return func(a, b interface{}) (int64, error) {
+		intA, err := toInt64(a)
+		if err != nil {
+			return 0, err
+		}
+		intB, err := toInt64(b)
+		if err != nil {
+			return 0, err
+		}
+		return op(intA, intB)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateEngine(t *testing.T) {
+	tests := []struct {
+		srcPath  string
+		marker   string
+		expected string
+	}{
+		{"server.properties.mustache", "", mustacheEngine},
+		{"server.properties", "", mustacheEngine},
+		{"server.properties.tmpl", "", goEngine},
+		{"server.properties.GOTMPL", "", goEngine},
+		{"server.properties", "go", goEngine},
+		{"server.properties.tmpl", " Mustache ", mustacheEngine},
+	}
+	for _, test := range tests {
+		engine, err := templateEngine(test.srcPath, test.marker)
+		assert.NoError(t, err, test.srcPath)
+		assert.Equal(t, test.expected, engine, test.srcPath)
+	}
+	_, err := templateEngine("server.properties", "jinja")
+	assert.Error(t, err)
+}
+
+func renderGo(t *testing.T, content string, env map[string]string, extraContext map[string]interface{}, strict bool) (string, error) {
+	return renderGoTemplate(content, "test.tmpl", t.TempDir(), env, extraContext, strict)
+}
+
+func TestRenderGoTemplateStrict(t *testing.T) {
+	extraContext := map[string]interface{}{
+		"task": map[string]interface{}{"ip": "10.0.0.1"},
+		"env": map[string]interface{}{
+			"BROKERS": []map[string]interface{}{{"value": "broker-0"}, {"value": "broker-1"}},
+		},
+	}
+	tests := []struct {
+		content  string
+		env      map[string]string
+		expected string
+	}{
+		// keys within conditional bodies may be missing when the body isn't rendered:
+		{"{{if .TLS_ENABLED}}{{.TLS_PORT}}{{end}}", map[string]string{}, ""},
+		{"{{if .TLS_ENABLED}}{{.TLS_PORT}}{{else}}{{.MISSING}}{{end}}", map[string]string{"TLS_ENABLED": "true", "TLS_PORT": "9093"}, "9093"},
+		{"{{with .SASL}}{{.}}{{else}}{{$.PORT}}{{end}}", map[string]string{"PORT": "9092"}, "9092"},
+		{"{{range .env.BROKERS}}{{.value}}:{{$.PORT}},{{end}}", map[string]string{"PORT": "9092"}, "broker-0:9092,broker-1:9092,"},
+		{"{{range .MISSING}}{{.}}{{end}}", map[string]string{}, ""},
+		// keys passed to default may be missing:
+		{"-Xmx{{default \"512\" .HEAP_MB}}M", map[string]string{}, "-Xmx512M"},
+		{"-Xmx{{.HEAP_MB | default \"512\"}}M", map[string]string{"HEAP_MB": "1024"}, "-Xmx1024M"},
+		{"-Xmx{{.HEAP_MB | default \"512\"}}M", map[string]string{}, "-Xmx512M"},
+		{"{{.task.ip}}", map[string]string{}, "10.0.0.1"},
+	}
+	for _, test := range tests {
+		rendered, err := renderGo(t, test.content, test.env, extraContext, true)
+		assert.NoError(t, err, test.content)
+		assert.Equal(t, test.expected, rendered, test.content)
+	}
+
+	_, err := renderGo(t, "{{.PORT}} {{.HOST}}{{if .X}}{{.Y}}{{end}}", map[string]string{}, extraContext, true)
+	assert.EqualError(t, err, "Template references 2 undefined key(s): HOST, PORT")
+}
+
+func TestRenderGoTemplateMissingKeys(t *testing.T) {
+	// missing keys render as empty, matching mustache:
+	rendered, err := renderGo(t, "port={{.PORT}} ip={{.task.ip}}", map[string]string{}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "port= ip=", rendered)
+
+	// only missing keys render as empty, while "<no value>" in the template's output is kept:
+	rendered, err = renderGo(t, "{{printf \"%s\" \"<no value>\"}}{{.PORT}}{{$port := .PORT}}{{$port}}{{if true}}[{{.task.ip}}]{{end}}",
+		map[string]string{}, map[string]interface{}{"task": map[string]interface{}{}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "<no value>[]", rendered)
+}
+
+func TestParseGoTemplateFields(t *testing.T) {
+	fields, err := parseGoTemplateFields(
+		"{{.PORT}}{{.env.HOST}}{{default 1 .COUNT}}{{.HEAP | default 512}}{{if .TLS}}{{.TLS_PORT}}{{end}}{{range .env.LIST}}{{.value}}{{$.SEP}}{{end}}")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"PORT": true, "env.HOST": true, "COUNT": true, "HEAP": true, "TLS": true, "TLS_PORT": true, "env.LIST": true, "SEP": true,
+	}, fields.referenced)
+	assert.Equal(t, map[string]bool{"PORT": true, "env": true}, fields.required)
+
+	_, err = parseGoTemplateFields("{{.PORT")
+	assert.Error(t, err)
+}
+
+func TestGoTemplateHelpers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "jvm.options"), []byte("-server"), 0644))
+	env := map[string]string{"MEMORY": "1024", "HOSTS": "a, b,,c", "PASSWORD": "secret", "EMPTY": ""}
+	extraContext := map[string]interface{}{
+		"list":  []map[string]interface{}{{"value": "x", "index": 0}, {"value": "y", "index": 1}},
+		"json":  []interface{}{"p", 2.0},
+		"count": 3,
+	}
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"{{default \"512\" .MISSING}}", "512"},
+		{"{{default \"512\" .EMPTY}}", "512"},
+		{"{{default \"512\" .MEMORY}}", "1024"},
+		{"{{div (mul .MEMORY 3) 4}}", "768"},
+		{"{{add .MEMORY .count}} {{sub .MEMORY 24}} {{mod .MEMORY 1000}}", "1027 1000 24"},
+		{"{{min .MEMORY 512}} {{max .MEMORY \"2048\"}}", "512 2048"},
+		{"{{join \",\" .list}}", "x,y"},
+		{"{{join \";\" .json}}", "p;2"},
+		{"{{join \",\" (split \",\" .HOSTS)}}", "a,b,c"},
+		{"{{range split \",\" .HOSTS}}[{{.}}]{{end}}", "[a][b][c]"},
+		{"{{b64dec (b64enc .PASSWORD)}}", "secret"},
+		{"{{toJson .json}}", "[\"p\",2]"},
+		{"{{toYaml .json}}", "- p\n- 2"},
+		{"{{include \"jvm.options\"}}", "-server"},
+	}
+	for _, test := range tests {
+		rendered, err := renderGoTemplate(test.content, "test.tmpl", dir, env, extraContext, false)
+		assert.NoError(t, err, test.content)
+		assert.Equal(t, test.expected, rendered, test.content)
+	}
+
+	for _, content := range []string{
+		"{{div .MEMORY 0}}",
+		"{{mul .MEMORY \"lots\"}}",
+		"{{join \",\" .count}}",
+		"{{required \"PASSWORD2 is required\" .PASSWORD2}}",
+		"{{include \"missing.options\"}}",
+	} {
+		_, err := renderGoTemplate(content, "test.tmpl", dir, env, extraContext, false)
+		assert.Error(t, err, content)
+	}
+}
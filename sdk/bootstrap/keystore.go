@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// java keystore/truststore generation
+
+const (
+	jksFormat    = "jks"
+	pkcs12Format = "pkcs12"
+
+	keystoreFileMode   = os.FileMode(0600)
+	truststoreFileMode = os.FileMode(0644)
+)
+
+// keystoreArgs describes the Java truststore and keystore to generate from the .ssl directory.
+type keystoreArgs struct {
+	// Whether to generate the stores
+	enabled bool
+	// Directory containing the CA, task certificate and private key. Relative paths below are within this directory.
+	sslDir string
+	// PEM CA certificate(s) for the truststore, and the keystore's certificate chain
+	caPath string
+	// PEM certificate (chain) and private key for the keystore. The keystore is skipped if either is missing.
+	certPath string
+	keyPath  string
+	// Store format: jks or pkcs12
+	format string
+
+	truststorePath     string
+	truststorePassword string
+	// Alias of the CA certificate. Additional certificates in caPath are suffixed: <alias>-1, <alias>-2, ...
+	truststoreAlias string
+
+	keystorePath     string
+	keystorePassword string
+	// Password for the keystore's private key entry (JKS only). Empty means the keystore password.
+	keystoreKeyPassword string
+	keystoreAlias       string
+}
+
+// resolve returns the path within sslDir, unless the path is absolute.
+func (a keystoreArgs) resolve(storePath string) string {
+	if filepath.IsAbs(storePath) {
+		return storePath
+	}
+	return filepath.Join(a.sslDir, storePath)
+}
+
+// defaultStorePath returns e.g. "truststore.jks" or "keystore.p12".
+func (a keystoreArgs) defaultStorePath(name string) string {
+	if a.format == pkcs12Format {
+		return name + ".p12"
+	}
+	return name + ".jks"
+}
+
+func readPEMCertificates(certPath string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	certs := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse certificate in '%s': %s", certPath, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No PEM certificates found in '%s'", certPath)
+	}
+	return certs, nil
+}
+
+// generateKeystores writes a truststore containing the CA certificate(s), and (if a task certificate
+// and private key are present) a keystore containing the key and its certificate chain. Existing
+// stores are replaced, so that reruns always produce the same entries.
+func generateKeystores(args keystoreArgs) {
+	caCerts, err := readPEMCertificates(args.resolve(args.caPath))
+	if err != nil {
+		log.Fatalf("Failed to read CA for truststore: %s", err)
+	}
+	truststorePath := args.resolve(args.truststorePath)
+	truststore, err := encodeTruststore(args, caCerts)
+	if err != nil {
+		log.Fatalf("Failed to generate truststore '%s': %s", truststorePath, err)
+	}
+	if err = writeFileAtomic(truststorePath, truststore, writeArgs{mode: truststoreFileMode, uid: -1, gid: -1}); err != nil {
+		log.Fatalf("Failed to write truststore '%s': %s", truststorePath, err)
+	}
+	log.Printf("Wrote %s truststore with %d CA certificate(s): %s", args.format, len(caCerts), truststorePath)
+
+	certPath, keyPath := args.resolve(args.certPath), args.resolve(args.keyPath)
+	certExists, _ := isFile(certPath)
+	keyExists, _ := isFile(keyPath)
+	if !certExists || !keyExists {
+		log.Printf("No task certificate and private key at '%s' and '%s'. Skipping keystore generation.", certPath, keyPath)
+		return
+	}
+	// Parses the certificate chain and key, and checks that they match:
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load task certificate and private key for keystore: %s", err)
+	}
+	chain := make([]*x509.Certificate, 0, len(keyPair.Certificate)+len(caCerts))
+	for _, der := range keyPair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			log.Fatalf("Failed to parse task certificate '%s': %s", certPath, err)
+		}
+		chain = append(chain, cert)
+	}
+	for _, caCert := range caCerts {
+		if !containsCertificate(chain, caCert) {
+			chain = append(chain, caCert)
+		}
+	}
+	keystorePath := args.resolve(args.keystorePath)
+	keystore, err := encodeKeystore(args, keyPair.PrivateKey, chain)
+	if err != nil {
+		log.Fatalf("Failed to generate keystore '%s': %s", keystorePath, err)
+	}
+	if err = writeFileAtomic(keystorePath, keystore, writeArgs{mode: keystoreFileMode, uid: -1, gid: -1}); err != nil {
+		log.Fatalf("Failed to write keystore '%s': %s", keystorePath, err)
+	}
+	log.Printf("Wrote %s keystore with a chain of %d certificate(s): %s", args.format, len(chain), keystorePath)
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// caAliases returns the truststore alias of each CA certificate.
+func caAliases(alias string, count int) []string {
+	aliases := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if i == 0 {
+			aliases = append(aliases, alias)
+		} else {
+			aliases = append(aliases, fmt.Sprintf("%s-%d", alias, i))
+		}
+	}
+	return aliases
+}
+
+func encodeTruststore(args keystoreArgs, caCerts []*x509.Certificate) ([]byte, error) {
+	aliases := caAliases(args.truststoreAlias, len(caCerts))
+	if args.format == pkcs12Format {
+		entries := make([]pkcs12.TrustStoreEntry, 0, len(caCerts))
+		for i, cert := range caCerts {
+			entries = append(entries, pkcs12.TrustStoreEntry{Cert: cert, FriendlyName: aliases[i]})
+		}
+		return pkcs12.LegacyDES.EncodeTrustStoreEntries(entries, args.truststorePassword)
+	}
+	jks := newJKSWriter(len(caCerts))
+	for i, cert := range caCerts {
+		jks.writeTrustedCert(aliases[i], cert)
+	}
+	return jks.finish(args.truststorePassword), nil
+}
+
+// encodeKeystore returns a keystore containing the private key and its certificate chain. For
+// PKCS12, the store and key passwords are the same, and Java derives the alias itself.
+func encodeKeystore(args keystoreArgs, key interface{}, chain []*x509.Certificate) ([]byte, error) {
+	if args.format == pkcs12Format {
+		return pkcs12.LegacyDES.Encode(key, chain[0], chain[1:], args.keystorePassword)
+	}
+	keyPassword := args.keystoreKeyPassword
+	if len(keyPassword) == 0 {
+		keyPassword = args.keystorePassword
+	}
+	jks := newJKSWriter(1)
+	if err := jks.writePrivateKey(args.keystoreAlias, key, chain, keyPassword); err != nil {
+		return nil, err
+	}
+	return jks.finish(args.keystorePassword), nil
+}
+
+// JKS encoding, matching sun.security.provider.JavaKeyStore
+
+const (
+	jksMagic          = 0xfeedfeed
+	jksVersion        = 2
+	jksPrivateKeyTag  = 1
+	jksTrustedCertTag = 2
+	// Integrity check "whitener" used by JavaKeyStore
+	jksDigestWhitener = "Mighty Aphrodite"
+)
+
+// OID of the proprietary algorithm used by sun.security.provider.KeyProtector
+var jksKeyProtectorOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+type jksWriter struct {
+	buf bytes.Buffer
+	// Entry timestamp, in milliseconds since epoch
+	timestamp int64
+}
+
+func newJKSWriter(entryCount int) *jksWriter {
+	w := &jksWriter{timestamp: time.Now().UnixNano() / int64(time.Millisecond)}
+	w.writeUint32(jksMagic)
+	w.writeUint32(jksVersion)
+	w.writeUint32(uint32(entryCount))
+	return w
+}
+
+func (w *jksWriter) writeUint32(value uint32) {
+	binary.Write(&w.buf, binary.BigEndian, value)
+}
+
+// writeUTF writes a string as java.io.DataOutput.writeUTF does, for ASCII aliases.
+func (w *jksWriter) writeUTF(value string) {
+	binary.Write(&w.buf, binary.BigEndian, uint16(len(value)))
+	w.buf.WriteString(value)
+}
+
+func (w *jksWriter) writeCert(cert *x509.Certificate) {
+	w.writeUTF("X.509")
+	w.writeUint32(uint32(len(cert.Raw)))
+	w.buf.Write(cert.Raw)
+}
+
+func (w *jksWriter) writeEntryHeader(tag uint32, alias string) {
+	w.writeUint32(tag)
+	// JKS aliases are case-insensitive and stored in lowercase:
+	w.writeUTF(strings.ToLower(alias))
+	binary.Write(&w.buf, binary.BigEndian, w.timestamp)
+}
+
+func (w *jksWriter) writeTrustedCert(alias string, cert *x509.Certificate) {
+	w.writeEntryHeader(jksTrustedCertTag, alias)
+	w.writeCert(cert)
+}
+
+func (w *jksWriter) writePrivateKey(alias string, key interface{}, chain []*x509.Certificate, password string) error {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("Failed to encode private key: %s", err)
+	}
+	protected, err := jksProtectKey(pkcs8, password)
+	if err != nil {
+		return err
+	}
+	encryptedKeyInfo, err := asn1.Marshal(struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: jksKeyProtectorOID, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to encode protected private key: %s", err)
+	}
+	w.writeEntryHeader(jksPrivateKeyTag, alias)
+	w.writeUint32(uint32(len(encryptedKeyInfo)))
+	w.buf.Write(encryptedKeyInfo)
+	w.writeUint32(uint32(len(chain)))
+	for _, cert := range chain {
+		w.writeCert(cert)
+	}
+	return nil
+}
+
+// finish appends the keystore's integrity digest and returns the encoded keystore.
+func (w *jksWriter) finish(password string) []byte {
+	digest := sha1.New()
+	digest.Write(jksPasswordBytes(password))
+	digest.Write([]byte(jksDigestWhitener))
+	digest.Write(w.buf.Bytes())
+	w.buf.Write(digest.Sum(nil))
+	return w.buf.Bytes()
+}
+
+// jksPasswordBytes returns the password as big-endian UTF-16, as used by JavaKeyStore and KeyProtector.
+func jksPasswordBytes(password string) []byte {
+	encoded := utf16.Encode([]rune(password))
+	passwordBytes := make([]byte, 0, 2*len(encoded))
+	for _, char := range encoded {
+		passwordBytes = append(passwordBytes, byte(char>>8), byte(char))
+	}
+	return passwordBytes
+}
+
+// jksProtectKey encrypts the PKCS8 key as sun.security.provider.KeyProtector does: the key is XORed
+// with a SHA1-based keystream seeded by a random salt, followed by a SHA1 checksum of the key.
+func jksProtectKey(plainKey []byte, password string) ([]byte, error) {
+	passwordBytes := jksPasswordBytes(password)
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	encrypted := make([]byte, len(plainKey))
+	digest := salt
+	for offset := 0; offset < len(plainKey); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(plainKey); i++ {
+			encrypted[offset+i] = plainKey[offset+i] ^ digest[i]
+		}
+	}
+	checksum := sha1.Sum(append(append([]byte{}, passwordBytes...), plainKey...))
+
+	protected := make([]byte, 0, len(salt)+len(encrypted)+len(checksum))
+	protected = append(protected, salt...)
+	protected = append(protected, encrypted...)
+	return append(protected, checksum[:]...), nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCertificate returns a certificate valid between notBefore and notAfter, signed by the
+// parent or self-signed if parent is nil, along with its private key.
+func newTestCertificate(t *testing.T, commonName string, isCA bool, notBefore, notAfter time.Time,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+func newValidTestCertificate(t *testing.T, commonName string, isCA bool,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	now := time.Now()
+	return newTestCertificate(t, commonName, isCA, now.Add(-time.Hour), now.Add(365*24*time.Hour), parent, parentKey)
+}
+
+func writeTestCertificates(t *testing.T, certPath string, certs ...*x509.Certificate) {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		assert.NoError(t, pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+	assert.NoError(t, ioutil.WriteFile(certPath, buf.Bytes(), 0644))
+}
+
+// jksEntry is an entry decoded from a JKS keystore.
+type jksEntry struct {
+	tag       uint32
+	alias     string
+	timestamp int64
+	// Private key entries only: the EncryptedPrivateKeyInfo
+	encryptedKey []byte
+	certs        []*x509.Certificate
+}
+
+// jksReader decodes keystores following sun.security.provider.JavaKeyStore.engineLoad.
+type jksReader struct {
+	t   *testing.T
+	buf *bytes.Reader
+}
+
+func (r jksReader) readUint32() uint32 {
+	var value uint32
+	assert.NoError(r.t, binary.Read(r.buf, binary.BigEndian, &value))
+	return value
+}
+
+func (r jksReader) readBytes(length int) []byte {
+	data := make([]byte, length)
+	_, err := r.buf.Read(data)
+	assert.NoError(r.t, err)
+	return data
+}
+
+func (r jksReader) readUTF() string {
+	var length uint16
+	assert.NoError(r.t, binary.Read(r.buf, binary.BigEndian, &length))
+	return string(r.readBytes(int(length)))
+}
+
+func (r jksReader) readCert() *x509.Certificate {
+	assert.Equal(r.t, "X.509", r.readUTF())
+	cert, err := x509.ParseCertificate(r.readBytes(int(r.readUint32())))
+	assert.NoError(r.t, err)
+	return cert
+}
+
+// decodeJKS checks the keystore's header and integrity digest, and returns its entries in order.
+func decodeJKS(t *testing.T, data []byte, password string) []jksEntry {
+	assert.True(t, len(data) > sha1.Size)
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	expectedDigest := sha1.New()
+	expectedDigest.Write(jksPasswordBytes(password))
+	expectedDigest.Write([]byte("Mighty Aphrodite"))
+	expectedDigest.Write(body)
+	assert.Equal(t, expectedDigest.Sum(nil), digest, "integrity digest")
+
+	r := jksReader{t: t, buf: bytes.NewReader(body)}
+	assert.Equal(t, uint32(0xfeedfeed), r.readUint32(), "magic")
+	assert.Equal(t, uint32(2), r.readUint32(), "version")
+	count := int(r.readUint32())
+	entries := make([]jksEntry, 0, count)
+	for i := 0; i < count; i++ {
+		entry := jksEntry{tag: r.readUint32(), alias: r.readUTF()}
+		assert.NoError(t, binary.Read(r.buf, binary.BigEndian, &entry.timestamp))
+		switch entry.tag {
+		case 1:
+			entry.encryptedKey = r.readBytes(int(r.readUint32()))
+			chainLength := int(r.readUint32())
+			for j := 0; j < chainLength; j++ {
+				entry.certs = append(entry.certs, r.readCert())
+			}
+		case 2:
+			entry.certs = append(entry.certs, r.readCert())
+		default:
+			t.Fatalf("Unexpected JKS entry tag %d", entry.tag)
+		}
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, 0, r.buf.Len(), "trailing data")
+	return entries
+}
+
+// unprotectJKSKey reverses sun.security.provider.KeyProtector, returning the PKCS8 private key.
+func unprotectJKSKey(t *testing.T, encryptedKeyInfo []byte, password string) interface{} {
+	var keyInfo struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}
+	_, err := asn1.Unmarshal(encryptedKeyInfo, &keyInfo)
+	assert.NoError(t, err)
+	assert.True(t, keyInfo.Algorithm.Algorithm.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}))
+
+	protected := keyInfo.EncryptedData
+	salt := protected[:sha1.Size]
+	encrypted := protected[sha1.Size : len(protected)-sha1.Size]
+	checksum := protected[len(protected)-sha1.Size:]
+	passwordBytes := jksPasswordBytes(password)
+
+	plainKey := make([]byte, len(encrypted))
+	digest := salt
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(encrypted); i++ {
+			plainKey[offset+i] = encrypted[offset+i] ^ digest[i]
+		}
+	}
+	expectedChecksum := sha1.Sum(append(append([]byte{}, passwordBytes...), plainKey...))
+	assert.Equal(t, expectedChecksum[:], checksum, "key checksum")
+
+	key, err := x509.ParsePKCS8PrivateKey(plainKey)
+	assert.NoError(t, err)
+	return key
+}
+
+// writeTestSSLDir writes two CAs and a task certificate signed by the first CA into a .ssl dir.
+func writeTestSSLDir(t *testing.T) (string, []*x509.Certificate, *x509.Certificate, *ecdsa.PrivateKey) {
+	sslDir := t.TempDir()
+	ca, caKey := newValidTestCertificate(t, "DC/OS Root CA", true, nil, nil)
+	otherCA, _ := newValidTestCertificate(t, "Other CA", true, nil, nil)
+	cert, key := newValidTestCertificate(t, "broker-0", false, ca, caKey)
+
+	writeTestCertificates(t, filepath.Join(sslDir, "ca-bundle.crt"), ca, otherCA)
+	writeTestCertificates(t, filepath.Join(sslDir, "test.crt"), cert)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sslDir, "test.key"),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return sslDir, []*x509.Certificate{ca, otherCA}, cert, key
+}
+
+func testKeystoreArgs(sslDir string, format string) keystoreArgs {
+	args := keystoreArgs{
+		enabled:            true,
+		sslDir:             sslDir,
+		caPath:             "ca-bundle.crt",
+		certPath:           "test.crt",
+		keyPath:            "test.key",
+		format:             format,
+		truststorePassword: "trust-secret",
+		truststoreAlias:    "DCOS-CA",
+		keystorePassword:   "store-secret",
+		keystoreAlias:      "Broker",
+	}
+	args.truststorePath = args.defaultStorePath("truststore")
+	args.keystorePath = args.defaultStorePath("keystore")
+	return args
+}
+
+func TestGenerateKeystoresJKS(t *testing.T) {
+	sslDir, caCerts, cert, key := writeTestSSLDir(t)
+	args := testKeystoreArgs(sslDir, jksFormat)
+	args.keystoreKeyPassword = "key-secret"
+	generateKeystores(args)
+
+	truststorePath := filepath.Join(sslDir, "truststore.jks")
+	info, err := os.Stat(truststorePath)
+	assert.NoError(t, err)
+	assert.Equal(t, truststoreFileMode, info.Mode().Perm())
+	data, err := ioutil.ReadFile(truststorePath)
+	assert.NoError(t, err)
+	entries := decodeJKS(t, data, "trust-secret")
+	assert.Equal(t, 2, len(entries))
+	// aliases are lowercased, with additional CAs suffixed in file order:
+	assert.Equal(t, uint32(2), entries[0].tag)
+	assert.Equal(t, "dcos-ca", entries[0].alias)
+	assert.True(t, entries[0].certs[0].Equal(caCerts[0]))
+	assert.Equal(t, uint32(2), entries[1].tag)
+	assert.Equal(t, "dcos-ca-1", entries[1].alias)
+	assert.True(t, entries[1].certs[0].Equal(caCerts[1]))
+
+	keystorePath := filepath.Join(sslDir, "keystore.jks")
+	info, err = os.Stat(keystorePath)
+	assert.NoError(t, err)
+	assert.Equal(t, keystoreFileMode, info.Mode().Perm())
+	data, err = ioutil.ReadFile(keystorePath)
+	assert.NoError(t, err)
+	entries = decodeJKS(t, data, "store-secret")
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, uint32(1), entries[0].tag)
+	assert.Equal(t, "broker", entries[0].alias)
+	// the chain is the task certificate followed by the CAs:
+	assert.Equal(t, 3, len(entries[0].certs))
+	assert.True(t, entries[0].certs[0].Equal(cert))
+	assert.True(t, entries[0].certs[1].Equal(caCerts[0]))
+	assert.True(t, entries[0].certs[2].Equal(caCerts[1]))
+	// the key is protected with the key password rather than the store password:
+	assert.Equal(t, key, unprotectJKSKey(t, entries[0].encryptedKey, "key-secret"))
+}
+
+func TestGenerateKeystoresPKCS12(t *testing.T) {
+	sslDir, caCerts, cert, key := writeTestSSLDir(t)
+	generateKeystores(testKeystoreArgs(sslDir, pkcs12Format))
+
+	data, err := ioutil.ReadFile(filepath.Join(sslDir, "truststore.p12"))
+	assert.NoError(t, err)
+	trusted, err := pkcs12.DecodeTrustStore(data, "trust-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(trusted))
+	assert.True(t, trusted[0].Equal(caCerts[0]))
+	assert.True(t, trusted[1].Equal(caCerts[1]))
+	_, err = pkcs12.DecodeTrustStore(data, "wrong")
+	assert.Error(t, err)
+
+	data, err = ioutil.ReadFile(filepath.Join(sslDir, "keystore.p12"))
+	assert.NoError(t, err)
+	decodedKey, decodedCert, decodedCAs, err := pkcs12.DecodeChain(data, "store-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, key, decodedKey)
+	assert.True(t, decodedCert.Equal(cert))
+	assert.Equal(t, 2, len(decodedCAs))
+	assert.True(t, decodedCAs[0].Equal(caCerts[0]))
+	assert.True(t, decodedCAs[1].Equal(caCerts[1]))
+}
+
+func TestGenerateKeystoresWithoutTaskCertificate(t *testing.T) {
+	sslDir, _, _, _ := writeTestSSLDir(t)
+	assert.NoError(t, os.Remove(filepath.Join(sslDir, "test.key")))
+	generateKeystores(testKeystoreArgs(sslDir, jksFormat))
+
+	exists, _ := isFile(filepath.Join(sslDir, "truststore.jks"))
+	assert.True(t, exists)
+	exists, _ = isFile(filepath.Join(sslDir, "keystore.jks"))
+	assert.False(t, exists)
+}
+
+func TestJKSPasswordBytes(t *testing.T) {
+	assert.Equal(t, []byte{0, 'a', 0, 'b'}, jksPasswordBytes("ab"))
+	assert.Equal(t, []byte{0x00, 0xe9, 0xd8, 0x3d, 0xde, 0x00}, jksPasswordBytes("é\U0001f600"))
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
-	// TODO switch to upstream once https://github.com/hoisie/mustache/pull/57 is merged:
 	"github.com/aryann/difflib"
+	// TODO switch to upstream once https://github.com/hoisie/mustache/pull/57 is merged:
 	"github.com/nickbp/mustache"
 	"io/ioutil"
 	"log"
@@ -15,6 +16,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,7 +26,7 @@ import (
 const (
 	configTemplatePrefix = "CONFIG_TEMPLATE_"
 	resolveRetryDelay    = time.Duration(1) * time.Second
-	mesos_dns            = "mesos"
+	mesos_dns            = "mesos" // Mesos-DNS domain of SRV records
 )
 
 var verbose = false
@@ -37,16 +39,49 @@ type args struct {
 	resolveEnabled bool
 	// Host resolution. Empty slice means disabled.
 	resolveHosts []string
+	// Number of hosts which must resolve, or zero for all of them
+	resolveQuorum int
 	// Timeout across all hosts. Zero means timeout disabled.
 	resolveTimeout time.Duration
+	// DNS server to resolve hosts with, or empty to use the system resolver
+	resolveDNSServer string
+
+	// Whether to discover the hostnames of sibling pod instances
+	peersEnabled bool
+	peers        peerArgs
+	// Whether to wait for the peers to resolve, and how many must resolve (zero for all of them)
+	peersWait   bool
+	peersQuorum int
+
+	// Dependencies which must be reachable before starting. Empty slice means disabled.
+	waitForTargets []waitTarget
+	// Number of targets which must be reachable, or zero for all of them
+	waitForQuorum int
+	// HTTP statuses which count as reachable, e.g. "200" or "2xx"
+	waitForHTTPStatuses []string
+	// Timeout for each target, and across all targets. Zero means timeout disabled.
+	waitForTargetTimeout time.Duration
+	waitForTimeout       time.Duration
 
 	// Whether to enable template logic
 	templateEnabled bool
 	// Max supported bytes or 0 for no limit
 	templateMaxBytes int64
+	// Typed template context, in addition to the flat envvars
+	templateContext templateContextArgs
+	// Whether to fail when templates reference undefined variables
+	templateStrict bool
+	// How rendered templates are written
+	templateWrite writeArgs
+	// How templates advertised as URLs are downloaded
+	templateFetch fetchArgs
 
 	// Install certs from .ssl into JRE/lib/security/cacerts
 	installCerts bool
+	// Java truststore/keystore generation from .ssl
+	keystores keystoreArgs
+	// PEM CA bundle for non-Java runtimes
+	caBundle caBundleArgs
 
 	// Get Task IP
 	getTaskIp bool
@@ -65,18 +100,134 @@ func parseArgs() args {
 	var rawHosts string
 	defaultHostString := "<TASK_NAME>.<FRAMEWORK_HOST>"
 	flag.StringVar(&rawHosts, "resolve-hosts", defaultHostString,
-		"Comma-separated list of hosts to resolve. Defaults to the hostname of the task itself.")
+		"Comma-separated list of hosts to resolve. Defaults to the hostname of the task itself. "+
+			fmt.Sprintf("Entries starting with '_' are Mesos-DNS SRV records, e.g. _node._tcp.hdfs.%s, "+
+				"where the .%s domain may be omitted.", mesos_dns, mesos_dns))
+	flag.IntVar(&args.resolveQuorum, "resolve-quorum", 0,
+		"Number of -resolve-hosts which must resolve, e.g. a majority of a peer list, or zero to require all of them.")
+	flag.StringVar(&args.resolveDNSServer, "resolve-dns-server", "",
+		"DNS server to resolve hosts with, as host or host:port. Defaults to the system resolver.")
 	flag.DurationVar(&args.resolveTimeout, "resolve-timeout", time.Duration(5)*time.Minute,
 		"Duration to wait for all host resolutions to complete, or zero to wait indefinitely.")
 
+	flag.BoolVar(&args.peersEnabled, "peers", false,
+		"Whether to discover the hostnames of all instances of this task's pod type, for use in templates.")
+	flag.StringVar(&args.peers.podType, "peers-pod-type", "",
+		"Pod type of the peers, e.g. 'node'. Defaults to the pod type in TASK_NAME.")
+	flag.StringVar(&args.peers.taskName, "peers-task", "",
+		"Task name of the peers within their pods, e.g. 'server'. Defaults to the task in TASK_NAME.")
+	flag.StringVar(&args.peers.countEnv, "peers-count-env", "",
+		"Envvar containing the number of pod instances, e.g. 'NODE_COUNT'.")
+	flag.IntVar(&args.peers.count, "peers-count", 0,
+		"Number of pod instances, or zero to read it from -peers-count-env.")
+	flag.BoolVar(&args.peersWait, "peers-wait", false,
+		"Whether to wait for the peer hostnames to resolve, using the -resolve-timeout and -resolve-dns-server settings.")
+	flag.IntVar(&args.peersQuorum, "peers-quorum", 0,
+		"Number of peers which must resolve with -peers-wait, or zero to require all of them.")
+
+	var rawWaitTargets string
+	flag.StringVar(&rawWaitTargets, "wait-for", "",
+		"Comma-separated list of dependencies to wait for after host resolution, as tcp://host:port, "+
+			"http://host:port/path or https://host:port/path. https targets are verified against the system CAs "+
+			"and $MESOS_SANDBOX/.ssl/ca.crt if present. Defaults to no dependencies.")
+	flag.IntVar(&args.waitForQuorum, "wait-for-quorum", 0,
+		"Number of -wait-for targets which must be reachable, or zero to require all of them.")
+	var rawHTTPStatuses string
+	flag.StringVar(&rawHTTPStatuses, "wait-for-http-status", "2xx",
+		"Comma-separated list of statuses which http(s) -wait-for targets must respond with, "+
+			"as codes (e.g. 200) or classes (e.g. 2xx).")
+	flag.DurationVar(&args.waitForTargetTimeout, "wait-for-target-timeout", time.Duration(0),
+		"Duration to wait for each -wait-for target before giving up on it, or zero to wait indefinitely.")
+	flag.DurationVar(&args.waitForTimeout, "wait-for-timeout", time.Duration(5)*time.Minute,
+		"Duration to wait for the -wait-for targets to be reachable, or zero to wait indefinitely.")
+
 	flag.BoolVar(&args.templateEnabled, "template", true,
 		fmt.Sprintf("Whether to enable processing of configuration templates advertised by %s* "+
-			"env vars.", configTemplatePrefix))
+			"env vars. Templates are rendered with mustache, or with go text/template if they have a %s "+
+			"extension or a matching %s<name>=%s envvar.", configTemplatePrefix,
+			strings.Join(goTemplateExtensions, " or "), templateEnginePrefix, goEngine))
 	flag.Int64Var(&args.templateMaxBytes, "template-max-bytes", 1024*1024,
 		"Largest template file that may be processed, or zero for no limit.")
+	flag.BoolVar(&args.templateContext.enabled, "template-context", false,
+		"Whether to expose a typed context to templates in addition to the flat envvars: "+
+			"envvars under 'env' (e.g. {{env.X}}, with lists and nested JSON values), and this task's "+
+			"ip, name, hostname, pod_index and framework_host under 'task' (e.g. {{task.ip}}).")
+	var rawListVars, rawJSONVars string
+	flag.StringVar(&rawListVars, "template-list-vars", "",
+		"Comma-separated list of envvars to expose as lists with -template-context, "+
+			"e.g. {{#env.X}}{{value}}{{^last}},{{/last}}{{/env.X}}.")
+	flag.StringVar(&args.templateContext.listSeparator, "template-list-separator", ",",
+		"Separator for splitting the values of -template-list-vars.")
+	flag.StringVar(&rawJSONVars, "template-json-vars", "",
+		"Comma-separated list of envvars to parse as JSON with -template-context, exposing nested values.")
+	flag.BoolVar(&args.templateStrict, "template-strict", false,
+		fmt.Sprintf("Whether to fail when a template references an undefined variable, rather than "+
+			"rendering it as an empty string. Templates may be checked in advance with '%s'.", validateTemplatesCommand))
+	flag.IntVar(&args.templateFetch.attempts, "template-fetch-attempts", 5,
+		"Number of attempts to download each template advertised as a URL (e.g. the scheduler's "+
+			"v1/artifacts/template/... endpoint), retrying connection failures and 5xx responses.")
+	flag.DurationVar(&args.templateFetch.timeout, "template-fetch-timeout", time.Duration(10)*time.Second,
+		"Timeout for each attempt to download a template.")
+	flag.StringVar(&args.templateFetch.caBundle, "template-fetch-ca", "",
+		"CA bundle for downloading templates over https, in addition to the system CAs. "+
+			"Empty means $MESOS_SANDBOX/.ssl/ca.crt, if present.")
+	flag.BoolVar(&args.templateFetch.fallback, "template-fetch-fallback", true,
+		fmt.Sprintf("Whether to use the template downloaded into %s/ by the Mesos fetcher when downloading "+
+			"a template fails. Downloaded or local templates are verified against any %s<name>=sha256:<hex> envvar.",
+			templateDownloadDir, templateChecksumPrefix))
+	flag.BoolVar(&args.templateWrite.dryRun, "dry-run", false,
+		"Whether to only print the changes that rendering templates would make, without writing any "+
+			"files or installing certs.")
+	var rawTemplateMode, rawTemplateOwner string
+	flag.StringVar(&rawTemplateMode, "template-mode", "",
+		"Octal mode for rendered templates, e.g. 0640. Empty means preserve the mode of existing "+
+			"files, or 0644 for new files.")
+	flag.StringVar(&rawTemplateOwner, "template-owner", "",
+		"Owner for rendered templates, as user[:group] names or ids. Empty means preserve the owner "+
+			"of existing files.")
 	flag.BoolVar(&args.installCerts, "install-certs", true,
 		"Whether to install certs from .ssl to the JRE.")
 
+	flag.BoolVar(&args.keystores.enabled, "keystores", false,
+		"Whether to generate a Java truststore from the CA in .ssl, and a keystore from the task's "+
+			"certificate and private key in .ssl (if present), without requiring keytool.")
+	flag.StringVar(&args.keystores.sslDir, "keystores-ssl-dir", "",
+		"Directory containing the CA, task certificate and private key, and where relative store "+
+			"paths are located. Empty means $MESOS_SANDBOX/.ssl.")
+	flag.StringVar(&args.keystores.caPath, "keystores-ca", "ca.crt",
+		"PEM CA certificate(s) to add to the truststore, and to the keystore's certificate chain.")
+	flag.StringVar(&args.keystores.certPath, "keystores-cert", "task.crt",
+		"PEM certificate (chain) of the task, for the keystore.")
+	flag.StringVar(&args.keystores.keyPath, "keystores-key", "task.key",
+		"PEM private key of the task, for the keystore.")
+	flag.StringVar(&args.keystores.format, "keystores-format", jksFormat,
+		fmt.Sprintf("Format of the generated stores: '%s' or '%s'.", jksFormat, pkcs12Format))
+	flag.StringVar(&args.keystores.truststorePath, "truststore-path", "",
+		"Path of the generated truststore. Empty means truststore.jks or truststore.p12, depending on the format.")
+	flag.StringVar(&args.keystores.truststorePassword, "truststore-password", "changeit",
+		"Password of the generated truststore.")
+	flag.StringVar(&args.keystores.truststoreAlias, "truststore-alias", "dcos-ca",
+		"Alias of the CA certificate in the truststore. Additional CA certificates are suffixed with -1, -2, ...")
+	flag.StringVar(&args.keystores.keystorePath, "keystore-path", "",
+		"Path of the generated keystore. Empty means keystore.jks or keystore.p12, depending on the format.")
+	flag.StringVar(&args.keystores.keystorePassword, "keystore-password", "changeit",
+		"Password of the generated keystore.")
+	flag.StringVar(&args.keystores.keystoreKeyPassword, "keystore-key-password", "",
+		"Password of the private key entry in a JKS keystore. Empty means the keystore password.")
+	flag.StringVar(&args.keystores.keystoreAlias, "keystore-alias", "task",
+		"Alias of the private key entry in a JKS keystore.")
+
+	flag.StringVar(&args.caBundle.path, "ca-bundle-path", "",
+		"Path of a PEM CA bundle to write for non-Java runtimes (OpenSSL, Python, Node), containing "+
+			"the cluster CA from .ssl/ca.crt. Empty means disabled.")
+	flag.BoolVar(&args.caBundle.includeSystem, "ca-bundle-system", true,
+		"Whether to include the system CAs in the CA bundle, in addition to the cluster CA.")
+	flag.StringVar(&args.caBundle.envFile, "ca-bundle-env-file", "",
+		fmt.Sprintf("Path of an env file to write with exports of %s pointing at the CA bundle, or empty for none.",
+			strings.Join(caBundleEnvVars, ", ")))
+	flag.StringVar(&args.caBundle.caPath, "ca-bundle-ca", "",
+		"PEM cluster CA to add to the CA bundle. Empty means $MESOS_SANDBOX/.ssl/ca.crt.")
+
 	flag.BoolVar(&args.getTaskIp, "get-task-ip", false, "Print task IP")
 
 	flag.Parse()
@@ -97,6 +248,46 @@ func parseArgs() args {
 		args.resolveHosts = splitAndClean(rawHosts, ",")
 	}
 
+	args.templateContext.listVars = splitAndClean(rawListVars, ",")
+	args.templateContext.jsonVars = splitAndClean(rawJSONVars, ",")
+
+	var err error
+	args.templateWrite.mode, err = parseFileMode(rawTemplateMode)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	args.templateWrite.uid, args.templateWrite.gid, err = parseOwner(rawTemplateOwner)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if args.keystores.format != jksFormat && args.keystores.format != pkcs12Format {
+		log.Fatalf("Invalid -keystores-format '%s': expected '%s' or '%s'", args.keystores.format, jksFormat, pkcs12Format)
+	}
+	if len(args.keystores.sslDir) == 0 {
+		args.keystores.sslDir = filepath.Join(os.Getenv("MESOS_SANDBOX"), ".ssl")
+	}
+	if len(args.caBundle.caPath) == 0 {
+		args.caBundle.caPath = filepath.Join(os.Getenv("MESOS_SANDBOX"), ".ssl", "ca.crt")
+	}
+	if len(args.caBundle.envFile) != 0 && len(args.caBundle.path) == 0 {
+		log.Fatalf("-ca-bundle-env-file requires -ca-bundle-path")
+	}
+	if len(args.keystores.truststorePath) == 0 {
+		args.keystores.truststorePath = args.keystores.defaultStorePath("truststore")
+	}
+	if len(args.keystores.keystorePath) == 0 {
+		args.keystores.keystorePath = args.keystores.defaultStorePath("keystore")
+	}
+
+	args.waitForTargets, err = parseWaitTargets(splitAndClean(rawWaitTargets, ","))
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	args.waitForHTTPStatuses, err = parseHTTPStatuses(rawHTTPStatuses)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
 	return args
 }
 
@@ -122,59 +313,150 @@ func printEnv() {
 
 // dns resolve
 
-func waitForResolve(resolveHosts []string, resolveTimeout time.Duration) {
-	var timer *time.Timer
-	if resolveTimeout == 0 {
-		timer = nil
-	} else {
-		timer = time.NewTimer(resolveTimeout)
+// newResolver returns a resolver which queries the provided DNS server ("host" or "host:port"), or
+// the system resolver if dnsServer is empty.
+func newResolver(dnsServer string) *net.Resolver {
+	if len(dnsServer) == 0 {
+		return net.DefaultResolver
 	}
-	for _, host := range resolveHosts {
-		log.Printf("Waiting for '%s' to resolve...", host)
-		for {
-			result, err := net.LookupHost(host)
-
-			// Check result, exit loop if suceeded:
-			if err != nil {
-				if verbose {
-					log.Printf("Lookup failed: %s", err)
-				}
-			} else if len(result) == 0 {
-				if verbose {
-					log.Printf("No results for host '%s'", host)
-				}
-			} else {
-				log.Printf("Resolved '%s' => %s", host, result)
-				break
-			}
+	if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+		dnsServer = net.JoinHostPort(dnsServer, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: resolveRetryDelay * 5}
+			return dialer.DialContext(ctx, network, dnsServer)
+		},
+	}
+}
 
-			// Check timeout:
-			if timer != nil {
-				select {
-				case _, ok := <-timer.C:
-					if ok {
-						log.Fatalf("Time ran out while resolving '%s'. "+
-							"Customize timeout with -resolve-timeout, or use -verbose to see attempts.", host)
-					} else {
-						log.Fatalf("Internal error: Channel closed")
-					}
-				default:
-					// do nothing
-				}
+// isSRVName returns whether the host is a SRV record name, e.g. "_node._tcp.hdfs.mesos".
+func isSRVName(host string) bool {
+	return strings.HasPrefix(host, "_")
+}
+
+// srvName returns the full name of a Mesos-DNS SRV record, adding the Mesos-DNS domain if it was
+// omitted, e.g. "_node._tcp.hdfs" => "_node._tcp.hdfs.mesos".
+func srvName(host string) string {
+	host = strings.TrimSuffix(host, ".")
+	if strings.HasSuffix(host, "."+mesos_dns) {
+		return host
+	}
+	return host + "." + mesos_dns
+}
+
+// lookup makes a single attempt to resolve the host, or the SRV record if host is a SRV name.
+func lookup(ctx context.Context, resolver *net.Resolver, host string) ([]string, error) {
+	if !isSRVName(host) {
+		return resolver.LookupHost(ctx, host)
+	}
+	_, records, err := resolver.LookupSRV(ctx, "", "", srvName(host))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(records))
+	for _, record := range records {
+		result = append(result, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+	}
+	return result, nil
+}
+
+// resolveHost retries the host until it resolves, then reports it on the resolved channel. Gives up
+// without reporting once ctx is cancelled.
+func resolveHost(ctx context.Context, resolver *net.Resolver, host string, resolved chan<- string) {
+	log.Printf("Waiting for '%s' to resolve...", host)
+	for {
+		result, err := lookup(ctx, resolver, host)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Check result, exit loop if suceeded:
+		if err != nil {
+			if verbose {
+				log.Printf("Lookup failed: %s", err)
 			}
+		} else if len(result) == 0 {
+			if verbose {
+				log.Printf("No results for host '%s'", host)
+			}
+		} else {
+			log.Printf("Resolved '%s' => %s", host, result)
+			resolved <- host
+			return
+		}
 
-			// Wait before retry:
-			time.Sleep(resolveRetryDelay)
+		// Wait before retry:
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resolveRetryDelay):
 		}
 	}
+}
 
-	if verbose {
-		log.Printf("Hosts resolved, continuing bootstrap.")
+// resolveQuorum returns the number of hosts which must resolve: quorum, or all of them if quorum is zero.
+func resolveQuorum(quorum int, hostCount int) (int, error) {
+	if quorum < 0 || quorum > hostCount {
+		return 0, fmt.Errorf("-resolve-quorum %d must be between 0 and the number of -resolve-hosts (%d)", quorum, hostCount)
+	}
+	if quorum == 0 {
+		return hostCount, nil
+	}
+	return quorum, nil
+}
+
+// waitForResolve resolves all hosts concurrently, until quorum of them have resolved, or all of
+// them if quorum is zero.
+func waitForResolve(resolveHosts []string, quorum int, resolveTimeout time.Duration, dnsServer string) {
+	quorum, err := resolveQuorum(quorum, len(resolveHosts))
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	var timeoutChan <-chan time.Time
+	if resolveTimeout != 0 {
+		timer := time.NewTimer(resolveTimeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
 	}
+	if len(dnsServer) > 0 {
+		log.Printf("Resolving hosts with DNS server %s", dnsServer)
+	}
+	resolver := newResolver(dnsServer)
 
-	// Clean up:
-	if !timer.Stop() {
-		<-timer.C
+	// stop resolving any remaining hosts once quorum is reached:
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resolved := make(chan string, len(resolveHosts))
+	for _, host := range resolveHosts {
+		go resolveHost(ctx, resolver, host, resolved)
+	}
+
+	pending := make(map[string]bool)
+	for _, host := range resolveHosts {
+		pending[host] = true
+	}
+	for count := 0; count < quorum; count++ {
+		select {
+		case host := <-resolved:
+			delete(pending, host)
+		case <-timeoutChan:
+			unresolved := make([]string, 0, len(pending))
+			for host := range pending {
+				unresolved = append(unresolved, host)
+			}
+			sort.Strings(unresolved)
+			log.Fatalf("Time ran out while resolving '%s' (%d of %d hosts resolved, %d required). "+
+				"Customize timeout with -resolve-timeout, or use -verbose to see attempts.",
+				strings.Join(unresolved, "', '"), count, len(resolveHosts), quorum)
+		}
+	}
+
+	if quorum < len(resolveHosts) {
+		log.Printf("%d of %d hosts resolved, continuing bootstrap.", quorum, len(resolveHosts))
+	} else if verbose {
+		log.Printf("Hosts resolved, continuing bootstrap.")
 	}
 }
 
@@ -210,34 +492,76 @@ func openTemplate(inPath string, source string, templateMaxBytes int64) []byte {
 	return data
 }
 
-func renderTemplate(origContent string, outPath string, envMap map[string]string, source string) {
+// renderTemplate renders the template with the envvars, along with any additional context such as
+// the list of peers. Envvars take precedence. In strict mode, undefined variables are an error.
+func renderTemplate(origContent string, outPath string, engine string, envMap map[string]string, extraContext map[string]interface{}, strict bool, write writeArgs, source string) {
 	dirpath, _ := path.Split(outPath)
-	template, err := mustache.ParseStringInDir(origContent, dirpath)
-	if err != nil {
-		log.Fatalf("Failed to parse template content from %s at '%s': %s", source, outPath, err)
+	var newContent string
+	if engine == goEngine {
+		var err error
+		newContent, err = renderGoTemplate(origContent, outPath, dirpath, envMap, extraContext, strict)
+		if err != nil {
+			log.Fatalf("Failed to render go template from %s at '%s': %s", source, outPath, err)
+		}
+	} else {
+		template, err := mustache.ParseStringInDir(origContent, dirpath)
+		if err != nil {
+			log.Fatalf("Failed to parse template content from %s at '%s': %s", source, outPath, err)
+		}
+		if strict {
+			if err = checkTemplateVariables(origContent, envMap, extraContext); err != nil {
+				log.Fatalf("Failed to render template from %s at '%s' with -template-strict: %s", source, outPath, err)
+			}
+		}
+		if extraContext != nil {
+			newContent = template.Render(envMap, extraContext)
+		} else {
+			newContent = template.Render(envMap)
+		}
+	}
+
+	// If the destination already exists, show the changes to it rather than to the template.
+	prevContent := origContent
+	existingContent, err := ioutil.ReadFile(outPath)
+	if err == nil {
+		if string(existingContent) == newContent {
+			log.Printf("Rendered '%s' from %s is unchanged (%d bytes), skipping write", outPath, source, len(newContent))
+			// still apply any explicit -template-mode or -template-owner to the existing file:
+			if !write.dryRun {
+				if err = applyFileAttributes(outPath, write); err != nil {
+					log.Fatalf("Failed to update mode/owner of '%s' for %s: %s", outPath, source, err)
+				}
+			}
+			return
+		}
+		prevContent = string(existingContent)
+	} else if !os.IsNotExist(err) {
+		log.Fatalf("Failed to read existing file at '%s' for %s: %s", outPath, source, err)
 	}
-	newContent := template.Render(envMap)
 
 	// Print a nice debuggable diff of the changes before they're written.
-	log.Printf("Writing rendered '%s' from %s with the following changes (%d bytes -> %d bytes):",
-		outPath, source, len(origContent), len(newContent))
-	for _, diffRec := range difflib.Diff(strings.Split(origContent, "\n"), strings.Split(newContent, "\n")) {
+	verb := "Writing"
+	if write.dryRun {
+		verb = "Dry run: would write"
+	}
+	log.Printf("%s rendered '%s' from %s with the following changes (%d bytes -> %d bytes):",
+		verb, outPath, source, len(prevContent), len(newContent))
+	for _, diffRec := range difflib.Diff(strings.Split(prevContent, "\n"), strings.Split(newContent, "\n")) {
 		fmt.Fprintf(os.Stderr, "%s\n", diffRec)
 	}
+	if write.dryRun {
+		return
+	}
 
-	err = ioutil.WriteFile(outPath, []byte(newContent), 0666) // mode shouldn't matter: file should exist
+	err = writeFileAtomic(outPath, []byte(newContent), write)
 	if err != nil {
 		log.Fatalf("Failed to write rendered template from %s to '%s': %s", source, outPath, err)
 	}
 }
 
-func renderTemplates(templateMaxBytes int64) {
+func renderTemplates(templateMaxBytes int64, extraContext map[string]interface{}, strict bool, write writeArgs, fetch fetchArgs) {
 	// Populate map with all envvars:
-	envMap := make(map[string]string)
-	for _, entry := range os.Environ() {
-		keyVal := strings.SplitN(entry, "=", 2) // entry: "key=val"
-		envMap[keyVal[0]] = keyVal[1]
-	}
+	envMap := readEnvMap()
 
 	// Handle CONFIG_TEMPLATE_* entries in env, passing them the full env map that we'd built above:
 	for _, entry := range os.Environ() {
@@ -245,16 +569,22 @@ func renderTemplates(templateMaxBytes int64) {
 			continue
 		}
 
-		envKeyVal := strings.SplitN(entry, "=", 2)      // entry: "CONFIG_TEMPLATE_<name>=<src-path>,<dest-path>"
-		srcDest := strings.SplitN(envKeyVal[1], ",", 2) // value: "<src-path>,<dest-path>"
+		envKeyVal := strings.SplitN(entry, "=", 2)      // entry: "CONFIG_TEMPLATE_<name>=<src-path-or-url>,<dest-path>"
+		srcDest := strings.SplitN(envKeyVal[1], ",", 2) // value: "<src-path-or-url>,<dest-path>"
 		if len(srcDest) != 2 {
 			log.Fatalf("Provided value for %s is invalid: Should be two strings separated by a comma, got: %s",
 				envKeyVal[0], envKeyVal[1])
 		}
 
 		source := fmt.Sprintf("envvar '%s'", envKeyVal[0])
-		data := openTemplate(srcDest[0], source, templateMaxBytes)
-		renderTemplate(string(data), srcDest[1], envMap, source)
+		engineKey := templateEnvKey(templateEnginePrefix, envKeyVal[0])
+		engine, err := templateEngine(srcDest[0], envMap[engineKey])
+		if err != nil {
+			log.Fatalf("Invalid engine for %s in envvar '%s': %s", source, engineKey, err)
+		}
+		checksum := envMap[templateEnvKey(templateChecksumPrefix, envKeyVal[0])]
+		data := loadTemplate(srcDest[0], source, templateMaxBytes, checksum, fetch)
+		renderTemplate(string(data), srcDest[1], engine, envMap, extraContext, strict, write, source)
 	}
 }
 
@@ -285,6 +615,10 @@ func installDCOSCertIntoJRE() {
 
 	cacertsPath := filepath.Join(javaHome, "lib", "security", "cacerts")
 	keytoolPath := filepath.Join(javaHome, "bin", "keytool")
+	// Remove the certificate from any previous run, so that the import doesn't fail on the existing
+	// alias. This fails if the alias isn't present, which is fine.
+	exec.Command(keytoolPath, "-delete", "-noprompt", "-alias", "dcoscert", "-keystore", cacertsPath,
+		"-storepass", "changeit").Run()
 	cmd := exec.Command(keytoolPath, "-importcert", "-noprompt", "-alias", "dcoscert", "-keystore", cacertsPath,
 		"-file", certPath, "-storepass", "changeit")
 	var out bytes.Buffer
@@ -357,16 +691,20 @@ func GetLocalIP() (addr string, err error) {
 // main
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == validateTemplatesCommand {
+		os.Exit(validateTemplates(os.Args[2:]))
+	}
+
 	args := parseArgs()
 
 	pod_ip, err := GetLocalIP()
 	if err != nil {
-		log.Fatalf("Cannot find the container's IP address: ", err)
+		log.Fatalf("Cannot find the container's IP address: %s", err)
 	}
 
 	err = os.Setenv("LIBPROCESS_IP", pod_ip)
 	if err != nil {
-		log.Fatalf("Failed to SET new LIBPROCESS_IP: ", err)
+		log.Fatalf("Failed to SET new LIBPROCESS_IP: %s", err)
 	}
 
 	if args.getTaskIp {
@@ -380,20 +718,60 @@ func main() {
 	}
 
 	if args.resolveEnabled {
-		waitForResolve(args.resolveHosts, args.resolveTimeout)
+		waitForResolve(args.resolveHosts, args.resolveQuorum, args.resolveTimeout, args.resolveDNSServer)
 	} else {
 		log.Printf("Resolve disabled via -resolve=false: Skipping host resolution")
 	}
 
+	var templateContext map[string]interface{}
+	if args.peersEnabled {
+		peers, selfIndex := discoverPeers(args.peers)
+		log.Printf("Discovered %d peers: %s", len(peers), strings.Join(peerHosts(peers), ", "))
+		if args.peersWait {
+			waitForResolve(peerHosts(peers), args.peersQuorum, args.resolveTimeout, args.resolveDNSServer)
+		}
+		templateContext = peersTemplateContext(peers, selfIndex)
+	}
+
+	if args.templateEnabled && args.templateContext.enabled {
+		typedContext, err := buildTemplateContext(args.templateContext, readEnvMap(), pod_ip)
+		if err != nil {
+			log.Fatalf("Failed to build template context: %s", err)
+		}
+		if templateContext != nil {
+			for key, value := range templateContext {
+				typedContext[key] = value
+			}
+		}
+		templateContext = typedContext
+	}
+
+	if len(args.waitForTargets) > 0 {
+		waitForTargets(args.waitForTargets, args.waitForQuorum, args.waitForHTTPStatuses,
+			args.waitForTargetTimeout, args.waitForTimeout)
+	}
+
 	if args.templateEnabled {
-		renderTemplates(args.templateMaxBytes)
+		renderTemplates(args.templateMaxBytes, templateContext, args.templateStrict, args.templateWrite, args.templateFetch)
 	} else {
 		log.Printf("Template handling disabled via -template=false: Skipping any config templates")
 	}
 
-	if args.installCerts {
+	if args.installCerts && args.templateWrite.dryRun {
+		log.Printf("Dry run via -dry-run: Skipping cert installation")
+	} else if args.installCerts {
 		installDCOSCertIntoJRE()
 	}
+	if args.keystores.enabled && args.templateWrite.dryRun {
+		log.Printf("Dry run via -dry-run: Skipping keystore generation")
+	} else if args.keystores.enabled {
+		generateKeystores(args.keystores)
+	}
+	if len(args.caBundle.path) != 0 && args.templateWrite.dryRun {
+		log.Printf("Dry run via -dry-run: Skipping CA bundle installation")
+	} else if len(args.caBundle.path) != 0 {
+		installCABundle(args.caBundle)
+	}
 	log.Printf("Local IP --> %s", pod_ip)
 	log.Printf("SDK Bootstrap successful.")
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRVName(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"_node._tcp.hdfs", "_node._tcp.hdfs.mesos"},
+		{"_node._tcp.hdfs.", "_node._tcp.hdfs.mesos"},
+		{"_node._tcp.hdfs.mesos", "_node._tcp.hdfs.mesos"},
+		{"_node._tcp.hdfs.mesos.", "_node._tcp.hdfs.mesos"},
+		{"_node._tcp.mesos-hdfs", "_node._tcp.mesos-hdfs.mesos"},
+		{"_node._tcp.hdfsmesos", "_node._tcp.hdfsmesos.mesos"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, srvName(test.host), test.host)
+	}
+}
+
+func TestIsSRVName(t *testing.T) {
+	assert.True(t, isSRVName("_node._tcp.hdfs"))
+	assert.False(t, isSRVName("name-0-node.hdfs.autoip.dcos.thisdcos.directory"))
+}
+
+func TestResolveQuorum(t *testing.T) {
+	tests := []struct {
+		quorum    int
+		hostCount int
+		expected  int
+		valid     bool
+	}{
+		{0, 3, 3, true},
+		{1, 3, 1, true},
+		{3, 3, 3, true},
+		{0, 0, 0, true},
+		{4, 3, 0, false},
+		{-1, 3, 0, false},
+		{1, 0, 0, false},
+	}
+	for _, test := range tests {
+		quorum, err := resolveQuorum(test.quorum, test.hostCount)
+		if test.valid {
+			assert.NoError(t, err, "%d of %d", test.quorum, test.hostCount)
+			assert.Equal(t, test.expected, quorum, "%d of %d", test.quorum, test.hostCount)
+		} else {
+			assert.Error(t, err, "%d of %d", test.quorum, test.hostCount)
+		}
+	}
+}
+
+func TestResolveHostCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	resolved := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		resolveHost(ctx, net.DefaultResolver, "unresolvable.invalid", resolved)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(5) * time.Second):
+		t.Fatal("resolveHost kept retrying after its context was cancelled")
+	}
+	assert.Empty(t, resolved)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// peer discovery
+
+const (
+	podInstanceIndexEnv = "POD_INSTANCE_INDEX"
+	peersTemplateKey    = "PEERS"
+)
+
+// peerArgs describes the sibling pod instances of this task.
+type peerArgs struct {
+	// Pod type, e.g. "node". Empty means derived from TASK_NAME.
+	podType string
+	// Task name within each pod, e.g. "server". Empty means derived from TASK_NAME.
+	taskName string
+	// Envvar containing the number of pod instances, e.g. "NODE_COUNT"
+	countEnv string
+	// Number of pod instances. Zero means read from countEnv.
+	count int
+}
+
+// peer is a sibling pod instance, exposed to templates as an entry of the PEERS list.
+type peer struct {
+	index int
+	host  string
+}
+
+// splitTaskName splits a task name into its pod type and task, e.g. "node-0-server" with index 0
+// => "node", "server".
+func splitTaskName(taskName string, index int) (string, string, error) {
+	separator := fmt.Sprintf("-%d-", index)
+	split := strings.LastIndex(taskName, separator)
+	if split <= 0 {
+		return "", "", fmt.Errorf("TASK_NAME '%s' isn't of the form <type>-%d-<task>", taskName, index)
+	}
+	return taskName[:split], taskName[split+len(separator):], nil
+}
+
+// discoverPeers builds the hostnames of all instances of this task's pod type, including this
+// task itself: <type>-<i>-<task>.<FRAMEWORK_HOST>. Returns the peers and this task's index.
+func discoverPeers(args peerArgs) ([]peer, int) {
+	rawIndex, ok := os.LookupEnv(podInstanceIndexEnv)
+	if !ok {
+		log.Fatalf("Missing required envvar for peer discovery: %s", podInstanceIndexEnv)
+	}
+	index, err := strconv.Atoi(rawIndex)
+	if err != nil {
+		log.Fatalf("Invalid %s value '%s': %s", podInstanceIndexEnv, rawIndex, err)
+	}
+	frameworkHost, ok := os.LookupEnv("FRAMEWORK_HOST")
+	if !ok {
+		log.Fatalf("Missing required envvar for peer discovery: FRAMEWORK_HOST")
+	}
+
+	podType, taskName := args.podType, args.taskName
+	if len(podType) == 0 || len(taskName) == 0 {
+		fullTaskName, ok := os.LookupEnv("TASK_NAME")
+		if !ok {
+			log.Fatalf("Missing required envvar(s) for peer discovery. " +
+				"Either specify -peers-pod-type and -peers-task or provide this envvar: TASK_NAME.")
+		}
+		derivedType, derivedTask, err := splitTaskName(fullTaskName, index)
+		if err != nil {
+			log.Fatalf("Unable to derive pod type for peer discovery: %s. Specify -peers-pod-type and -peers-task.", err)
+		}
+		if len(podType) == 0 {
+			podType = derivedType
+		}
+		if len(taskName) == 0 {
+			taskName = derivedTask
+		}
+	}
+
+	count := args.count
+	if count == 0 {
+		if len(args.countEnv) == 0 {
+			log.Fatalf("Peer discovery requires either -peers-count or -peers-count-env.")
+		}
+		rawCount, ok := os.LookupEnv(args.countEnv)
+		if !ok {
+			log.Fatalf("Missing envvar from -peers-count-env: %s", args.countEnv)
+		}
+		count, err = strconv.Atoi(rawCount)
+		if err != nil || count <= 0 {
+			log.Fatalf("Invalid pod count in envvar %s: '%s'", args.countEnv, rawCount)
+		}
+	}
+	if index >= count {
+		log.Fatalf("%s %d is outside of the %d pod instances", podInstanceIndexEnv, index, count)
+	}
+
+	peers := make([]peer, 0, count)
+	for i := 0; i < count; i++ {
+		peers = append(peers, peer{index: i, host: fmt.Sprintf("%s-%d-%s.%s", podType, i, taskName, frameworkHost)})
+	}
+	return peers, index
+}
+
+func peerHosts(peers []peer) []string {
+	hosts := make([]string, 0, len(peers))
+	for _, p := range peers {
+		hosts = append(hosts, p.host)
+	}
+	return hosts
+}
+
+// peersTemplateContext returns the peers for use in mustache templates:
+//   - PEERS: list of {host, index, self, first, last}, matching the items of -template-context
+//     lists, e.g. for "{{#PEERS}}{{host}}:7000{{^last}},{{/last}}{{/PEERS}}"
+//   - OTHER_PEERS: the same list without this task, with first and last relative to that list
+//   - PEER_HOSTS: comma-separated hostnames of all peers
+//   - PEER_COUNT: number of peers
+func peersTemplateContext(peers []peer, selfIndex int) map[string]interface{} {
+	others := make([]peer, 0, len(peers))
+	for _, p := range peers {
+		if p.index != selfIndex {
+			others = append(others, p)
+		}
+	}
+	return map[string]interface{}{
+		peersTemplateKey: peerList(peers, selfIndex),
+		"OTHER_PEERS":    peerList(others, selfIndex),
+		"PEER_HOSTS":     strings.Join(peerHosts(peers), ","),
+		"PEER_COUNT":     strconv.Itoa(len(peers)),
+	}
+}
+
+func peerList(peers []peer, selfIndex int) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(peers))
+	for i, p := range peers {
+		list = append(list, map[string]interface{}{
+			"host":  p.host,
+			"index": p.index,
+			"self":  p.index == selfIndex,
+			"first": i == 0,
+			"last":  i == len(peers)-1,
+		})
+	}
+	return list
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTaskName(t *testing.T) {
+	tests := []struct {
+		taskName string
+		index    int
+		podType  string
+		task     string
+	}{
+		{"node-0-server", 0, "node", "server"},
+		{"node-12-server", 12, "node", "server"},
+		{"name-node-1-format", 1, "name-node", "format"},
+		// pod type which itself contains "-0-": the last separator is the one added by the SDK
+		{"rack-0-node-0-server", 0, "rack-0-node", "server"},
+		{"rack-0-node-1-server", 1, "rack-0-node", "server"},
+	}
+	for _, test := range tests {
+		podType, task, err := splitTaskName(test.taskName, test.index)
+		assert.NoError(t, err, test.taskName)
+		assert.Equal(t, test.podType, podType, test.taskName)
+		assert.Equal(t, test.task, task, test.taskName)
+	}
+
+	for _, invalid := range []string{"node-1-server", "-0-server", "node-0", "node"} {
+		_, _, err := splitTaskName(invalid, 0)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPeersTemplateContext(t *testing.T) {
+	peers := []peer{
+		{index: 0, host: "node-0-server.cassandra.autoip.dcos.thisdcos.directory"},
+		{index: 1, host: "node-1-server.cassandra.autoip.dcos.thisdcos.directory"},
+		{index: 2, host: "node-2-server.cassandra.autoip.dcos.thisdcos.directory"},
+	}
+	context := peersTemplateContext(peers, 1)
+
+	assert.Equal(t, "3", context["PEER_COUNT"])
+	assert.Equal(t, "node-0-server.cassandra.autoip.dcos.thisdcos.directory,"+
+		"node-1-server.cassandra.autoip.dcos.thisdcos.directory,"+
+		"node-2-server.cassandra.autoip.dcos.thisdcos.directory", context["PEER_HOSTS"])
+
+	all := context[peersTemplateKey].([]map[string]interface{})
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, map[string]interface{}{
+		"host":  "node-1-server.cassandra.autoip.dcos.thisdcos.directory",
+		"index": 1,
+		"self":  true,
+		"first": false,
+		"last":  false,
+	}, all[1])
+	assert.Equal(t, true, all[0]["first"])
+	assert.Equal(t, true, all[2]["last"])
+
+	others := context["OTHER_PEERS"].([]map[string]interface{})
+	assert.Equal(t, 2, len(others))
+	assert.Equal(t, 0, others[0]["index"])
+	assert.Equal(t, 2, others[1]["index"])
+	// first and last are relative to the list without this task:
+	assert.Equal(t, true, others[0]["first"])
+	assert.Equal(t, true, others[1]["last"])
+	for _, other := range others {
+		assert.Equal(t, false, other["self"])
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// template variable checks
+
+// templateTag is a mustache tag referencing a name, e.g. "{{FOO}}" or "{{#FOO}}".
+type templateTag struct {
+	// Tag type: 0 for a variable, or the tag's sigil: '#' or '^' to open a section, '/' to close one
+	kind byte
+	name string
+	line int
+}
+
+func (t templateTag) String() string {
+	return fmt.Sprintf("%s (line %d)", t.name, t.line)
+}
+
+// parseTemplateTags returns the tags in a mustache template which reference names. Comments and
+// partials are skipped, and custom delimiters aren't supported.
+func parseTemplateTags(content string) ([]templateTag, error) {
+	tags := make([]templateTag, 0)
+	remaining := content
+	line := 1
+	for {
+		start := strings.Index(remaining, "{{")
+		if start < 0 {
+			return tags, nil
+		}
+		line += strings.Count(remaining[:start], "\n")
+		remaining = remaining[start+2:]
+		closing := "}}"
+		if strings.HasPrefix(remaining, "{") {
+			// triple mustache: {{{name}}}
+			closing = "}}}"
+			remaining = remaining[1:]
+		}
+		end := strings.Index(remaining, closing)
+		if end < 0 {
+			return nil, fmt.Errorf("Unclosed tag on line %d", line)
+		}
+		body := strings.TrimSpace(remaining[:end])
+		line += strings.Count(remaining[:end], "\n")
+		remaining = remaining[end+len(closing):]
+		if len(body) == 0 {
+			continue
+		}
+		tag := templateTag{line: line}
+		switch body[0] {
+		case '!', '>':
+			continue
+		case '=':
+			return nil, fmt.Errorf("Custom delimiters on line %d aren't supported", line)
+		case '#', '^', '/':
+			tag.kind = body[0]
+			tag.name = strings.TrimSpace(body[1:])
+		case '&', '{':
+			tag.name = strings.TrimSpace(body[1:])
+		default:
+			tag.name = body
+		}
+		tags = append(tags, tag)
+	}
+}
+
+// lookupName looks up a (possibly dotted) name in a single context.
+func lookupName(context interface{}, name string) (interface{}, bool) {
+	current := context
+	for _, key := range strings.Split(name, ".") {
+		switch typedCurrent := current.(type) {
+		case map[string]string:
+			value, ok := typedCurrent[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case map[string]interface{}:
+			value, ok := typedCurrent[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// sectionContext returns the context which a section's content is rendered against: for lists,
+// this is the union of the keys of its items.
+func sectionContext(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]string, map[string]interface{}:
+		return typedValue
+	case []map[string]interface{}:
+		union := make(map[string]interface{})
+		for _, item := range typedValue {
+			for key, child := range item {
+				union[key] = child
+			}
+		}
+		return union
+	case []interface{}:
+		union := make(map[string]interface{})
+		for _, item := range typedValue {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				for key, child := range itemMap {
+					union[key] = child
+				}
+			}
+		}
+		return union
+	default:
+		return nil
+	}
+}
+
+// isFalsy returns whether a section with this value is skipped by mustache.
+func isFalsy(value interface{}, ok bool) bool {
+	if !ok || value == nil {
+		return true
+	}
+	switch typedValue := value.(type) {
+	case bool:
+		return !typedValue
+	case string:
+		return len(typedValue) == 0
+	case []map[string]interface{}:
+		return len(typedValue) == 0
+	case []interface{}:
+		return len(typedValue) == 0
+	}
+	return false
+}
+
+// findUndefinedVariables returns the variable tags which aren't defined in the contexts. Sections
+// may reference undefined names, as these are treated as false, and content within sections which
+// wouldn't be rendered isn't checked.
+func findUndefinedVariables(tags []templateTag, contexts ...interface{}) []templateTag {
+	type section struct {
+		name    string
+		context interface{}
+		skipped bool
+	}
+	stack := make([]section, 0)
+	lookup := func(name string) (interface{}, bool) {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].context != nil {
+				if value, ok := lookupName(stack[i].context, name); ok {
+					return value, true
+				}
+			}
+		}
+		for _, context := range contexts {
+			if value, ok := lookupName(context, name); ok {
+				return value, true
+			}
+		}
+		return nil, false
+	}
+	skipped := func() bool {
+		for _, s := range stack {
+			if s.skipped {
+				return true
+			}
+		}
+		return false
+	}
+
+	undefined := make([]templateTag, 0)
+	for _, tag := range tags {
+		switch tag.kind {
+		case '#':
+			value, ok := lookup(tag.name)
+			stack = append(stack, section{name: tag.name, context: sectionContext(value), skipped: isFalsy(value, ok)})
+		case '^':
+			value, ok := lookup(tag.name)
+			stack = append(stack, section{name: tag.name, skipped: !isFalsy(value, ok)})
+		case '/':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if tag.name == "." || skipped() {
+				continue
+			}
+			if _, ok := lookup(tag.name); !ok {
+				undefined = append(undefined, tag)
+			}
+		}
+	}
+	return undefined
+}
+
+// referencedNames returns the top-level names referenced by the tags. Names within the typed
+// context's 'env' namespace are returned without the namespace, e.g. "env.X" => "X".
+func referencedNames(tags []templateTag) map[string]bool {
+	names := make(map[string]bool)
+	for _, tag := range tags {
+		if tag.kind == '/' {
+			continue
+		}
+		names[referencedName(tag.name)] = true
+	}
+	return names
+}
+
+// referencedName returns the top-level name of a (possibly dotted) reference, without any 'env'
+// namespace, e.g. "env.X.y" => "X".
+func referencedName(name string) string {
+	return strings.SplitN(strings.TrimPrefix(name, "env."), ".", 2)[0]
+}
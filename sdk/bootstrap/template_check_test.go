@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplateTags(t *testing.T) {
+	tags, err := parseTemplateTags(`listen={{HOST}}:{{{PORT}}}
+{{! a comment }}{{> partial.yml}}
+{{#BROKERS}}
+  {{&value}}{{^last}},{{/last}}
+{{/BROKERS}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []templateTag{
+		{kind: 0, name: "HOST", line: 1},
+		{kind: 0, name: "PORT", line: 1},
+		{kind: '#', name: "BROKERS", line: 3},
+		{kind: 0, name: "value", line: 4},
+		{kind: '^', name: "last", line: 4},
+		{kind: '/', name: "last", line: 4},
+		{kind: '/', name: "BROKERS", line: 5},
+	}, tags)
+}
+
+func TestParseTemplateTagsMultilineTag(t *testing.T) {
+	tags, err := parseTemplateTags("a\n{{!\nmultiline\ncomment\n}}\n{{ FOO }}")
+	assert.NoError(t, err)
+	assert.Equal(t, []templateTag{{kind: 0, name: "FOO", line: 6}}, tags)
+}
+
+func TestParseTemplateTagsErrors(t *testing.T) {
+	_, err := parseTemplateTags("a\n{{=<% %>=}}\n<% FOO %>")
+	assert.EqualError(t, err, "Custom delimiters on line 2 aren't supported")
+
+	_, err = parseTemplateTags("a\nb {{FOO")
+	assert.EqualError(t, err, "Unclosed tag on line 2")
+
+	_, err = parseTemplateTags("{{{FOO}}")
+	assert.Error(t, err)
+}
+
+func undefinedNames(t *testing.T, content string, contexts ...interface{}) []string {
+	tags, err := parseTemplateTags(content)
+	assert.NoError(t, err)
+	names := make([]string, 0)
+	for _, tag := range findUndefinedVariables(tags, contexts...) {
+		names = append(names, tag.String())
+	}
+	return names
+}
+
+func TestFindUndefinedVariables(t *testing.T) {
+	env := map[string]string{"HOST": "broker-0", "TLS_ENABLED": "", "SASL_ENABLED": "true"}
+
+	assert.Equal(t, []string{"PORT (line 2)"}, undefinedNames(t, "{{HOST}}\n{{{PORT}}}", env))
+	assert.Equal(t, []string{"PORT (line 1)"}, undefinedNames(t, "{{&PORT}}", env))
+
+	// sections which won't be rendered aren't checked, and may reference undefined names:
+	assert.Equal(t, []string{}, undefinedNames(t, "{{#TLS_ENABLED}}{{TLS_PORT}}{{/TLS_ENABLED}}", env))
+	assert.Equal(t, []string{}, undefinedNames(t, "{{#MISSING}}{{TLS_PORT}}{{/MISSING}}", env))
+	assert.Equal(t, []string{"SASL_MECHANISM (line 1)"},
+		undefinedNames(t, "{{#SASL_ENABLED}}{{SASL_MECHANISM}}{{/SASL_ENABLED}}", env))
+
+	// inverted sections are rendered when the value is falsy:
+	assert.Equal(t, []string{"PLAINTEXT_PORT (line 1)"},
+		undefinedNames(t, "{{^TLS_ENABLED}}{{PLAINTEXT_PORT}}{{/TLS_ENABLED}}", env))
+	assert.Equal(t, []string{}, undefinedNames(t, "{{^SASL_ENABLED}}{{PLAINTEXT_PORT}}{{/SASL_ENABLED}}", env))
+}
+
+func TestFindUndefinedVariablesNestedSections(t *testing.T) {
+	context := map[string]interface{}{
+		"env": map[string]interface{}{
+			"BROKERS": []map[string]interface{}{
+				{"value": "broker-0", "index": 0, "first": true, "last": false},
+				{"value": "broker-1", "index": 1, "first": false, "last": true},
+			},
+			"LISTENERS": []map[string]interface{}{},
+		},
+		"task": map[string]interface{}{"ip": "10.0.0.1"},
+	}
+	env := map[string]string{"PORT": "9092"}
+
+	// item keys, outer context keys and envvars are all visible within the section:
+	assert.Equal(t, []string{}, undefinedNames(t,
+		"{{#env.BROKERS}}{{value}}:{{PORT}}@{{task.ip}}{{^last}},{{/last}}{{/env.BROKERS}}", env, context))
+	assert.Equal(t, []string{"host (line 2)"}, undefinedNames(t,
+		"{{#env.BROKERS}}\n{{host}}{{#first}}{{index}}{{/first}}\n{{/env.BROKERS}}", env, context))
+	// names within a nested skipped section aren't checked:
+	assert.Equal(t, []string{}, undefinedNames(t,
+		"{{#env.BROKERS}}{{#env.LISTENERS}}{{name}}{{/env.LISTENERS}}{{/env.BROKERS}}", env, context))
+	assert.Equal(t, []string{"task.hostname (line 1)"}, undefinedNames(t,
+		"{{#task}}{{ip}}{{/task}}{{task.hostname}}", env, context))
+}
+
+func TestReferencedNames(t *testing.T) {
+	tags, err := parseTemplateTags("{{HOST}}{{#env.BROKERS}}{{value}}{{/env.BROKERS}}{{task.ip}}{{env.PORT}}")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"HOST": true, "BROKERS": true, "value": true, "task": true, "PORT": true},
+		referencedNames(tags))
+}
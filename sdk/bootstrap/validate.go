@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nickbp/mustache"
+)
+
+// template validation subcommand
+
+const validateTemplatesCommand = "validate-templates"
+
+// checkTemplateVariables returns an error listing any variables in the template which aren't
+// defined in the envvars or the additional context.
+func checkTemplateVariables(content string, envMap map[string]string, extraContext map[string]interface{}) error {
+	tags, err := parseTemplateTags(content)
+	if err != nil {
+		return err
+	}
+	undefined := findUndefinedVariables(tags, envMap, extraContext)
+	if len(undefined) == 0 {
+		return nil
+	}
+	entries := make([]string, 0, len(undefined))
+	for _, tag := range undefined {
+		entries = append(entries, tag.String())
+	}
+	return fmt.Errorf("Template references %d undefined variable(s): %s", len(undefined), strings.Join(entries, ", "))
+}
+
+// readEnvFile reads a sample env file of KEY=VALUE lines, ignoring blank lines and '#' comments.
+func readEnvFile(envFile string) (map[string]string, error) {
+	file, err := os.Open(envFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyVal := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		if len(keyVal) != 2 || len(strings.TrimSpace(keyVal[0])) == 0 {
+			return nil, fmt.Errorf("Line %d of %s isn't of the form KEY=VALUE: %s", lineNum, envFile, line)
+		}
+		env[strings.TrimSpace(keyVal[0])] = keyVal[1]
+	}
+	return env, scanner.Err()
+}
+
+// listTemplates returns the regular files in dir which match any of the include patterns and none
+// of the exclude patterns.
+func listTemplates(dir string, include, exclude []string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	matchesAny := func(name string, patterns []string) (bool, error) {
+		for _, pattern := range patterns {
+			matched, err := filepath.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("Invalid pattern '%s': %s", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	paths := make([]string, 0)
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		included, err := matchesAny(file.Name(), include)
+		if err != nil {
+			return nil, err
+		}
+		excluded, err := matchesAny(file.Name(), exclude)
+		if err != nil {
+			return nil, err
+		}
+		if included && !excluded {
+			paths = append(paths, filepath.Join(dir, file.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// validateTemplates renders every template in a directory against a sample env file, reporting
+// undefined variables in each (mustache or go) template and env file entries which no template uses. Returns the
+// process exit code: non-zero if any template failed to parse or had undefined variables.
+func validateTemplates(argv []string) int {
+	flags := flag.NewFlagSet(validateTemplatesCommand, flag.ExitOnError)
+	dir := flags.String("dir", "",
+		"Directory containing the templates to validate, e.g. 'src/main/dist'.")
+	envFile := flags.String("env-file", "",
+		"File of sample KEY=VALUE envvars to render the templates with. Blank lines and '#' comments are ignored.")
+	rawInclude := flags.String("include", "*",
+		"Comma-separated filename patterns of the templates to validate, e.g. '*.mustache,*.yaml'.")
+	rawExclude := flags.String("exclude", "svc.yml",
+		"Comma-separated filename patterns to skip, e.g. the service spec which is rendered by the scheduler.")
+	engineMarker := flags.String("engine", "",
+		fmt.Sprintf("Engine to validate all templates with, '%s' or '%s'. By default, templates with a %s "+
+			"extension use %s and others use %s.", mustacheEngine, goEngine,
+			strings.Join(goTemplateExtensions, " or "), goEngine, mustacheEngine))
+	var contextArgs templateContextArgs
+	flags.BoolVar(&contextArgs.enabled, "template-context", false,
+		"Whether to validate against the typed template context, as with bootstrap's -template-context.")
+	var rawListVars, rawJSONVars string
+	flags.StringVar(&rawListVars, "template-list-vars", "",
+		"Comma-separated list of envvars to expose as lists with -template-context.")
+	flags.StringVar(&contextArgs.listSeparator, "template-list-separator", ",",
+		"Separator for splitting the values of -template-list-vars.")
+	flags.StringVar(&rawJSONVars, "template-json-vars", "",
+		"Comma-separated list of envvars to parse as JSON with -template-context.")
+	flags.Parse(argv)
+
+	if len(*dir) == 0 || len(*envFile) == 0 {
+		fmt.Fprintf(os.Stderr, "Both -dir and -env-file are required.\n")
+		flags.Usage()
+		return 2
+	}
+	env, err := readEnvFile(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read env file: %s\n", err)
+		return 2
+	}
+	var extraContext map[string]interface{}
+	if contextArgs.enabled {
+		contextArgs.listVars = splitAndClean(rawListVars, ",")
+		contextArgs.jsonVars = splitAndClean(rawJSONVars, ",")
+		// the task's IP is only known at runtime, use a placeholder:
+		extraContext, err = buildTemplateContext(contextArgs, env, "127.0.0.1")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to build template context: %s\n", err)
+			return 2
+		}
+	}
+	paths, err := listTemplates(*dir, splitAndClean(*rawInclude, ","), splitAndClean(*rawExclude, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list templates in '%s': %s\n", *dir, err)
+		return 2
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "No templates found in '%s' matching -include and -exclude.\n", *dir)
+		return 2
+	}
+
+	failed := 0
+	used := make(map[string]bool)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Printf("%s: failed to read: %s\n", path, err)
+			failed++
+			continue
+		}
+		engine, err := templateEngine(path, *engineMarker)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 2
+		}
+		if engine == goEngine {
+			if !validateGoTemplate(path, string(data), env, extraContext, used) {
+				failed++
+			}
+		} else if !validateMustacheTemplate(path, string(data), env, extraContext, used) {
+			failed++
+		}
+	}
+
+	unused := make([]string, 0)
+	for key := range env {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		fmt.Printf("%d variable(s) in %s aren't used by any template:\n", len(unused), *envFile)
+		for _, key := range unused {
+			fmt.Printf("  %s\n", key)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d templates failed validation.\n", failed, len(paths))
+		return 1
+	}
+	fmt.Printf("All %d templates passed validation.\n", len(paths))
+	return 0
+}
+
+// validateMustacheTemplate prints any undefined variables in the template, and adds the variables
+// it references to used. Returns whether the template passed validation.
+func validateMustacheTemplate(path string, content string, env map[string]string, extraContext map[string]interface{}, used map[string]bool) bool {
+	// ensure that mustache itself can render the template, along with our own tag checks:
+	template, err := mustache.ParseStringInDir(content, filepath.Dir(path))
+	if err != nil {
+		fmt.Printf("%s: failed to parse: %s\n", path, err)
+		return false
+	}
+	template.Render(env, extraContext)
+	tags, err := parseTemplateTags(content)
+	if err != nil {
+		fmt.Printf("%s: failed to parse: %s\n", path, err)
+		return false
+	}
+	for name := range referencedNames(tags) {
+		used[name] = true
+	}
+	undefined := findUndefinedVariables(tags, env, extraContext)
+	if len(undefined) == 0 {
+		fmt.Printf("%s: OK (%d tags)\n", path, len(tags))
+		return true
+	}
+	fmt.Printf("%s: %d undefined variable(s):\n", path, len(undefined))
+	for _, tag := range undefined {
+		fmt.Printf("  %s\n", tag)
+	}
+	return false
+}
+
+// validateGoTemplate renders the go template in strict mode, printing any undefined keys, and
+// adds the keys it references to used. Returns whether the template passed validation.
+func validateGoTemplate(path string, content string, env map[string]string, extraContext map[string]interface{}, used map[string]bool) bool {
+	fields, err := parseGoTemplateFields(content)
+	if err != nil {
+		fmt.Printf("%s: failed to parse: %s\n", path, err)
+		return false
+	}
+	for name := range fields.referenced {
+		used[referencedName(name)] = true
+	}
+	if _, err = renderGoTemplate(content, path, filepath.Dir(path), env, extraContext, true); err != nil {
+		fmt.Printf("%s: failed to render: %s\n", path, err)
+		return false
+	}
+	fmt.Printf("%s: OK (%d keys)\n", path, len(fields.referenced))
+	return true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGoTemplateUsedKeys(t *testing.T) {
+	env := map[string]string{"PORT": "9092", "HEAP_MB": "512", "UNUSED": "x"}
+	extraContext := map[string]interface{}{
+		"env":  map[string]interface{}{"PORT": "9092", "HEAP_MB": "512", "UNUSED": "x"},
+		"task": map[string]interface{}{"ip": "10.0.0.1"},
+	}
+	used := make(map[string]bool)
+	assert.True(t, validateGoTemplate("server.properties.tmpl",
+		"listeners={{.task.ip}}:{{.env.PORT}}\nheap={{$.HEAP_MB}}\n", env, extraContext, used))
+	// references within the env namespace count as uses of the envvar itself:
+	assert.Equal(t, map[string]bool{"task": true, "PORT": true, "HEAP_MB": true}, used)
+
+	assert.False(t, validateGoTemplate("server.properties.tmpl", "{{.MISSING}}", env, extraContext, used))
+}
+
+func TestReadEnvFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "sample.env")
+	assert.NoError(t, ioutil.WriteFile(envFile, []byte("# sample\n\nPORT=9092\nexport HOST=broker-0\nOPTS=-Da=b\n"), 0644))
+	env, err := readEnvFile(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PORT": "9092", "HOST": "broker-0", "OPTS": "-Da=b"}, env)
+
+	assert.NoError(t, ioutil.WriteFile(envFile, []byte("PORT=9092\nHOST\n"), 0644))
+	_, err = readEnvFile(envFile)
+	assert.Error(t, err)
+}
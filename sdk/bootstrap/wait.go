@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dependency wait
+
+const (
+	waitForRetryDelay     = time.Duration(1) * time.Second
+	waitForAttemptTimeout = time.Duration(5) * time.Second
+)
+
+// waitTarget is a dependency which must be reachable before the task may start, e.g.
+// "tcp://zookeeper-0:2181" or "http://journal-0:8480/jmx".
+type waitTarget struct {
+	raw string
+	url *url.URL
+}
+
+func parseWaitTargets(rawTargets []string) ([]waitTarget, error) {
+	targets := make([]waitTarget, 0, len(rawTargets))
+	for _, raw := range rawTargets {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid -wait-for target '%s': %s", raw, err)
+		}
+		switch parsed.Scheme {
+		case "tcp":
+			if _, _, err := net.SplitHostPort(parsed.Host); err != nil {
+				return nil, fmt.Errorf("Invalid -wait-for target '%s': expected tcp://host:port", raw)
+			}
+		case "http", "https":
+			if len(parsed.Host) == 0 {
+				return nil, fmt.Errorf("Invalid -wait-for target '%s': missing host", raw)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid -wait-for target '%s': expected tcp://, http:// or https://", raw)
+		}
+		targets = append(targets, waitTarget{raw: raw, url: parsed})
+	}
+	return targets, nil
+}
+
+// parseHTTPStatuses parses a comma-separated list of HTTP statuses, where each entry is either a
+// code (e.g. "200") or a class of codes (e.g. "2xx").
+func parseHTTPStatuses(rawStatuses string) ([]string, error) {
+	statuses := splitAndClean(rawStatuses, ",")
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("Invalid -wait-for-http-status: no statuses provided")
+	}
+	for _, status := range statuses {
+		lower := strings.ToLower(status)
+		if len(lower) == 3 && strings.HasSuffix(lower, "xx") && lower[0] >= '1' && lower[0] <= '5' {
+			continue
+		}
+		if code, err := strconv.Atoi(status); err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("Invalid -wait-for-http-status entry '%s': expected a code like 200 or a class like 2xx", status)
+		}
+	}
+	return statuses, nil
+}
+
+func httpStatusMatches(code int, statuses []string) bool {
+	for _, status := range statuses {
+		lower := strings.ToLower(status)
+		if strings.HasSuffix(lower, "xx") {
+			if strconv.Itoa(code)[0] == lower[0] {
+				return true
+			}
+		} else if strconv.Itoa(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// check makes a single attempt to reach the target, returning an error describing why it isn't
+// reachable yet, or a description of the successful attempt.
+func (t waitTarget) check(httpClient *http.Client, httpStatuses []string) (string, error) {
+	if t.url.Scheme == "tcp" {
+		conn, err := net.DialTimeout("tcp", t.url.Host, waitForAttemptTimeout)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		return fmt.Sprintf("connected to %s", conn.RemoteAddr()), nil
+	}
+	response, err := httpClient.Get(t.url.String())
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	if !httpStatusMatches(response.StatusCode, httpStatuses) {
+		return "", fmt.Errorf("got response '%s', expected %s", response.Status, strings.Join(httpStatuses, " or "))
+	}
+	return fmt.Sprintf("got response '%s'", response.Status), nil
+}
+
+type waitResult struct {
+	target waitTarget
+	ready  bool
+}
+
+// waitForTarget retries the target until it's reachable or the target timeout (if any) has passed.
+func waitForTarget(httpClient *http.Client, target waitTarget, targetTimeout time.Duration, httpStatuses []string, results chan<- waitResult) {
+	var deadline time.Time
+	if targetTimeout != 0 {
+		deadline = time.Now().Add(targetTimeout)
+	}
+	log.Printf("Waiting for '%s' to be reachable...", target.raw)
+	for {
+		description, err := target.check(httpClient, httpStatuses)
+		if err == nil {
+			log.Printf("Reached '%s': %s", target.raw, description)
+			results <- waitResult{target: target, ready: true}
+			return
+		}
+		if verbose {
+			log.Printf("Attempt to reach '%s' failed: %s", target.raw, err)
+		}
+		if !deadline.IsZero() && time.Now().Add(waitForRetryDelay).After(deadline) {
+			log.Printf("Time ran out while waiting for '%s'. Last error: %s", target.raw, err)
+			results <- waitResult{target: target, ready: false}
+			return
+		}
+		time.Sleep(waitForRetryDelay)
+	}
+}
+
+// waitForTargets waits until quorum of the targets are reachable, or all of them if quorum is zero.
+// Targets are checked in parallel: each target is given up on after targetTimeout, and the wait as a
+// whole fails after timeout. Zero timeouts are disabled.
+func waitForTargets(targets []waitTarget, quorum int, httpStatuses []string, targetTimeout, timeout time.Duration) {
+	if quorum < 0 || quorum > len(targets) {
+		log.Fatalf("-wait-for-quorum %d must be between 0 and the number of -wait-for targets (%d)", quorum, len(targets))
+	}
+	if quorum == 0 {
+		quorum = len(targets)
+	}
+	var timeoutChan <-chan time.Time
+	if timeout != 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	// https targets are verified against the DC/OS CA from the sandbox, like fetched templates:
+	httpClient, err := newFetchClient(fetchArgs{timeout: waitForAttemptTimeout})
+	if err != nil {
+		log.Fatalf("Failed to set up client for -wait-for targets: %s", err)
+	}
+	results := make(chan waitResult, len(targets))
+	for _, target := range targets {
+		go waitForTarget(httpClient, target, targetTimeout, httpStatuses, results)
+	}
+
+	ready := 0
+	failed := make([]string, 0)
+	for ready < quorum {
+		select {
+		case result := <-results:
+			if result.ready {
+				ready++
+				continue
+			}
+			failed = append(failed, result.target.raw)
+			if len(targets)-len(failed) < quorum {
+				log.Fatalf("Gave up waiting for %s: %d of %d targets are reachable, and %d are required. "+
+					"Customize timeout with -wait-for-target-timeout, or use -verbose to see attempts.",
+					strings.Join(failed, ", "), ready, len(targets), quorum)
+			}
+		case <-timeoutChan:
+			log.Fatalf("Time ran out while waiting for -wait-for targets: %d of %d targets are reachable, and %d are required. "+
+				"Customize timeout with -wait-for-timeout, or use -verbose to see attempts.", ready, len(targets), quorum)
+		}
+	}
+
+	if quorum < len(targets) {
+		log.Printf("%d of %d targets reachable, continuing bootstrap.", ready, len(targets))
+	} else if verbose {
+		log.Printf("Targets reachable, continuing bootstrap.")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWaitTargets(t *testing.T) {
+	tests := []struct {
+		raw   string
+		valid bool
+	}{
+		{"tcp://zookeeper-0:2181", true},
+		{"http://journal-0:8480/jmx", true},
+		{"https://journal-0.hdfs.autoip.dcos.thisdcos.directory:8480", true},
+		{"tcp://zookeeper-0", false},
+		{"http:///jmx", false},
+		{"udp://zookeeper-0:2181", false},
+		{"zookeeper-0:2181", false},
+		{"%zz", false},
+	}
+	for _, test := range tests {
+		targets, err := parseWaitTargets([]string{test.raw})
+		if test.valid {
+			assert.NoError(t, err, test.raw)
+			assert.Equal(t, 1, len(targets), test.raw)
+			assert.Equal(t, test.raw, targets[0].raw)
+		} else {
+			assert.Error(t, err, test.raw)
+		}
+	}
+}
+
+func TestParseHTTPStatuses(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"2xx", []string{"2xx"}},
+		{"200", []string{"200"}},
+		{" 200 , 3XX ,", []string{"200", "3XX"}},
+		{"", nil},
+		{",", nil},
+		{"6xx", nil},
+		{"2x", nil},
+		{"x2x", nil},
+		{"99", nil},
+		{"600", nil},
+		{"ok", nil},
+		{"200,ok", nil},
+	}
+	for _, test := range tests {
+		statuses, err := parseHTTPStatuses(test.raw)
+		if test.expected != nil {
+			assert.NoError(t, err, test.raw)
+			assert.Equal(t, test.expected, statuses, test.raw)
+		} else {
+			assert.Error(t, err, test.raw)
+		}
+	}
+}
+
+func TestHTTPStatusMatches(t *testing.T) {
+	tests := []struct {
+		code     int
+		statuses []string
+		expected bool
+	}{
+		{200, []string{"2xx"}, true},
+		{204, []string{"2xx"}, true},
+		{301, []string{"2xx"}, false},
+		{301, []string{"2xx", "3XX"}, true},
+		{200, []string{"200"}, true},
+		{201, []string{"200"}, false},
+		{401, []string{"200", "401"}, true},
+		{500, []string{}, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, httpStatusMatches(test.code, test.statuses), "%d %s", test.code, test.statuses)
+	}
+}
+
+func TestWaitForHTTPSTarget(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	targets, err := parseWaitTargets([]string{server.URL + "/jmx"})
+	assert.NoError(t, err)
+
+	sandbox := t.TempDir()
+	prevSandbox, hadSandbox := os.LookupEnv("MESOS_SANDBOX")
+	os.Setenv("MESOS_SANDBOX", sandbox)
+	defer func() {
+		if hadSandbox {
+			os.Setenv("MESOS_SANDBOX", prevSandbox)
+		} else {
+			os.Unsetenv("MESOS_SANDBOX")
+		}
+	}()
+
+	// without the sandbox CA, the server's certificate isn't trusted:
+	httpClient, err := newFetchClient(fetchArgs{timeout: waitForAttemptTimeout})
+	assert.NoError(t, err)
+	results := make(chan waitResult, 1)
+	waitForTarget(httpClient, targets[0], time.Millisecond, []string{"2xx"}, results)
+	assert.False(t, (<-results).ready)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(sandbox, ".ssl"), 0755))
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sandbox, ".ssl", "ca.crt"), caPEM, 0644))
+	httpClient, err = newFetchClient(fetchArgs{timeout: waitForAttemptTimeout})
+	assert.NoError(t, err)
+	waitForTarget(httpClient, targets[0], time.Millisecond, []string{"2xx"}, results)
+	assert.True(t, (<-results).ready)
+
+	// waitForTargets exits on failure, so returning at all means the target was reached:
+	waitForTargets(targets, 0, []string{"2xx"}, 0, time.Duration(5)*time.Second)
+}
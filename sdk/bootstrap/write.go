@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// atomic file writes
+
+const defaultNewFileMode = os.FileMode(0644)
+
+// writeArgs describes how rendered files are written.
+type writeArgs struct {
+	// Whether to only print the changes, without writing anything
+	dryRun bool
+	// Mode for written files. Zero means preserve the existing file's mode, or 0644 for new files.
+	mode os.FileMode
+	// Owner for written files. -1 means preserve the existing file's owner, or the current user for new files.
+	uid int
+	gid int
+}
+
+// parseFileMode parses an octal mode, e.g. "0640". Empty means zero.
+func parseFileMode(rawMode string) (os.FileMode, error) {
+	if len(rawMode) == 0 {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(rawMode, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("Invalid file mode '%s': expected octal permissions like 0644", rawMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseOwner parses an owner of the form "user[:group]", where user and group are names or numeric
+// ids. Empty means -1 (preserve) for both.
+func parseOwner(rawOwner string) (int, int, error) {
+	if len(rawOwner) == 0 {
+		return -1, -1, nil
+	}
+	userGroup := strings.SplitN(rawOwner, ":", 2)
+	uid, err := strconv.Atoi(userGroup[0])
+	if err != nil {
+		found, err := user.Lookup(userGroup[0])
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid owner '%s': %s", rawOwner, err)
+		}
+		uid, _ = strconv.Atoi(found.Uid)
+	}
+	if len(userGroup) == 1 {
+		return uid, -1, nil
+	}
+	gid, err := strconv.Atoi(userGroup[1])
+	if err != nil {
+		found, err := user.LookupGroup(userGroup[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid owner '%s': %s", rawOwner, err)
+		}
+		gid, _ = strconv.Atoi(found.Gid)
+	}
+	return uid, gid, nil
+}
+
+// applyFileAttributes applies the mode and owner explicitly provided in args, if any, to an existing
+// file.
+func applyFileAttributes(outPath string, args writeArgs) error {
+	if args.mode != 0 {
+		if err := os.Chmod(outPath, args.mode); err != nil {
+			return err
+		}
+	}
+	if args.uid != -1 || args.gid != -1 {
+		if err := os.Chown(outPath, args.uid, args.gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes the content to a temp file alongside outPath and then renames it into
+// place, so that readers never see a partially written file. Parent directories are created as
+// needed. Unless overridden in args, the mode and owner of any existing file are preserved. If
+// outPath is a symlink, the file it points to is replaced rather than the link itself.
+func writeFileAtomic(outPath string, content []byte, args writeArgs) error {
+	resolvedPath, err := filepath.EvalSymlinks(outPath)
+	if err == nil {
+		outPath = resolvedPath
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	dir := filepath.Dir(outPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	mode, uid, gid := args.mode, args.uid, args.gid
+	preserveOwner := false
+	existing, err := os.Stat(outPath)
+	if err == nil {
+		if mode == 0 {
+			mode = existing.Mode().Perm()
+		}
+		if stat, ok := existing.Sys().(*syscall.Stat_t); ok && uid == -1 && gid == -1 {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+			preserveOwner = true
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if mode == 0 {
+		mode = defaultNewFileMode
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.tmp", filepath.Base(outPath)))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	// no-op once the temp file has been renamed:
+	defer os.Remove(tmpPath)
+
+	if _, err = tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err = os.Chown(tmpPath, uid, gid); err != nil {
+			if !preserveOwner {
+				return err
+			}
+			// unprivileged users can't give away files. not fatal when just preserving the owner:
+			log.Printf("Unable to preserve owner %d:%d of '%s': %s", uid, gid, outPath, err)
+		}
+	}
+	return os.Rename(tmpPath, outPath)
+}
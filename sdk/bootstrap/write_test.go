@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var preserveArgs = writeArgs{uid: -1, gid: -1}
+
+func assertFile(t *testing.T, path string, content string, mode os.FileMode) {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, mode, info.Mode().Perm())
+}
+
+// assertNoTempFiles checks that no temp files were left behind in dir.
+func assertNoTempFiles(t *testing.T, dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp*"))
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestWriteFileAtomicNewFile(t *testing.T) {
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "conf", "nested", "server.properties")
+	assert.NoError(t, writeFileAtomic(outPath, []byte("port=9092\n"), preserveArgs))
+	assertFile(t, outPath, "port=9092\n", defaultNewFileMode)
+	assertNoTempFiles(t, filepath.Dir(outPath))
+}
+
+func TestWriteFileAtomicPreservesMode(t *testing.T) {
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "server.properties")
+	assert.NoError(t, ioutil.WriteFile(outPath, []byte("old"), 0600))
+	assert.NoError(t, os.Chmod(outPath, 0600))
+
+	assert.NoError(t, writeFileAtomic(outPath, []byte("new"), preserveArgs))
+	assertFile(t, outPath, "new", 0600)
+
+	args := preserveArgs
+	args.mode = 0640
+	assert.NoError(t, writeFileAtomic(outPath, []byte("newer"), args))
+	assertFile(t, outPath, "newer", 0640)
+	assertNoTempFiles(t, dir)
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	targetPath := filepath.Join(dir, "server.properties")
+	linkPath := filepath.Join(dir, "link.properties")
+	assert.NoError(t, ioutil.WriteFile(targetPath, []byte("old"), 0600))
+	assert.NoError(t, os.Symlink(targetPath, linkPath))
+
+	assert.NoError(t, writeFileAtomic(linkPath, []byte("new"), preserveArgs))
+	// the link is kept, and the file it points to is replaced:
+	linkInfo, err := os.Lstat(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, linkInfo.Mode()&os.ModeSymlink)
+	assertFile(t, targetPath, "new", 0600)
+}
+
+func TestWriteFileAtomicCleansUpOnError(t *testing.T) {
+	dir := t.TempDir()
+	// renaming a file onto a directory fails after the temp file has been written:
+	outPath := filepath.Join(dir, "server.properties")
+	assert.NoError(t, os.MkdirAll(filepath.Join(outPath, "child"), 0755))
+
+	assert.Error(t, writeFileAtomic(outPath, []byte("new"), preserveArgs))
+	assertNoTempFiles(t, dir)
+}
+
+func TestApplyFileAttributes(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "server.properties")
+	assert.NoError(t, ioutil.WriteFile(outPath, []byte("content"), 0644))
+	assert.NoError(t, os.Chmod(outPath, 0644))
+
+	assert.NoError(t, applyFileAttributes(outPath, preserveArgs))
+	assertFile(t, outPath, "content", 0644)
+
+	args := preserveArgs
+	args.mode = 0600
+	assert.NoError(t, applyFileAttributes(outPath, args))
+	assertFile(t, outPath, "content", 0600)
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := parseFileMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0), mode)
+	mode, err = parseFileMode("0640")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+	mode, err = parseFileMode("755")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), mode)
+
+	for _, invalid := range []string{"0", "0800", "1777", "rw-r--r--"} {
+		_, err = parseFileMode(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	uid, gid, err := parseOwner("")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{-1, -1}, []int{uid, gid})
+	uid, gid, err = parseOwner("1000")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, -1}, []int{uid, gid})
+	uid, gid, err = parseOwner("1000:2000")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, 2000}, []int{uid, gid})
+	uid, gid, err = parseOwner("root:0")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0}, []int{uid, gid})
+
+	_, _, err = parseOwner("no-such-user-for-test")
+	assert.Error(t, err)
+	_, _, err = parseOwner("0:no-such-group-for-test")
+	assert.Error(t, err)
+}